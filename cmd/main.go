@@ -1,29 +1,88 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
 	"net/http"
 
 	"github.com/3ssalunke/videoverse/controllers"
 	"github.com/3ssalunke/videoverse/db"
 	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/services"
 	"github.com/3ssalunke/videoverse/utils"
+	"github.com/3ssalunke/videoverse/workers"
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
 	db.Init()
 
+	if err := services.LoadSigningKeys(); err != nil {
+		if !errors.Is(err, services.ErrNoSigningKeys) {
+			log.Fatal("failed to load signing keys: ", err)
+		}
+		log.Printf("%s is not set, signed urls are disabled", services.SIGNING_KEYS_ENV)
+	}
+
 	port := ":8080"
 
+	reaperInterval, linkRetention, err := workers.LoadSharedLinkReaperSettings()
+	if err != nil {
+		log.Fatal("failed to load shared link reaper settings: ", err)
+	}
+
+	sharedLinkReaper := workers.NewSharedLinkReaper(
+		repository.NewSharedLinkRepository(db.DB),
+		reaperInterval,
+		linkRetention,
+		workers.SHARED_LINK_REAPER_BATCH,
+	)
+	sharedLinkReaper.Start(context.Background())
+
+	trashJanitor := workers.NewTrashJanitor(
+		repository.NewVideoRepository(db.DB),
+		new(utils.OSFileSystem),
+		workers.TRASH_JANITOR_INTERVAL,
+		workers.TRASH_RETENTION_PERIOD,
+		workers.TRASH_JANITOR_BATCH,
+	)
+	trashJanitor.Start(context.Background())
+
+	videoImporter := workers.NewVideoImporter(
+		repository.NewImportJobRepository(db.DB),
+		repository.NewVideoRepository(db.DB),
+		workers.VIDEO_IMPORTER_CONCURRENCY,
+		workers.VIDEO_IMPORTER_QUEUE_SIZE,
+	)
+	videoImporter.Start(context.Background())
+
 	r := gin.Default()
 
 	api := r.Group("/api")
 	{
 		videoV1 := api.Group("/v1/videos")
+		sharedV1 := api.Group("/v1/shared")
+		sharesV1 := api.Group("/v1/shares")
+		signedV1 := api.Group("/v1/signed")
+		uploadsV1 := api.Group("/v1/uploads")
 		videoRepo := repository.NewVideoRepository(db.DB)
 		fileSystem := new(utils.OSFileSystem)
 		videoController := controllers.NewVideoController(videoRepo, fileSystem)
+		sharedLinkRepo := repository.NewSharedLinkRepository(db.DB)
+		sharedLinkController := controllers.NewSharedLinkController(videoRepo, sharedLinkRepo, fileSystem)
+		uploadController := controllers.NewUploadController(videoRepo, services.NewTusStore(services.TUS_UPLOAD_DIR))
+		importController := controllers.NewImportController(repository.NewImportJobRepository(db.DB), videoImporter)
+
+		{
+			sharedV1.GET("/:id", sharedLinkController.StreamSharedVideo)
+			sharedV1.HEAD("/:id", sharedLinkController.StreamSharedVideo)
+		}
+
+		{
+			signedV1.GET("/:id", sharedLinkController.StreamSignedVideo)
+			signedV1.HEAD("/:id", sharedLinkController.StreamSignedVideo)
+		}
 
 		{
 			videoV1.GET("/health-check", func(c *gin.Context) {
@@ -34,9 +93,45 @@ func main() {
 
 			videoV1.Use(controllers.AuthMiddleware())
 
+			videoV1.GET("", videoController.ListVideos)
+			videoV1.GET("/search", videoController.SearchVideos)
+			videoV1.GET("/trash", videoController.ListTrash)
+			videoV1.DELETE("/trash", videoController.EmptyTrash)
 			videoV1.POST("/upload", videoController.UploadVideo)
 			videoV1.POST("/trim", videoController.TrimVideo)
 			videoV1.POST("/merge", videoController.MergeVideos)
+			videoV1.POST("/import", importController.CreateImport)
+			videoV1.GET("/imports/:id", importController.GetImport)
+			videoV1.GET("/:id", videoController.GetVideo)
+			videoV1.PATCH("/:id", videoController.UpdateVideo)
+			videoV1.DELETE("/:id", videoController.DeleteVideo)
+			videoV1.GET("/:id/lineage", videoController.GetVideoLineage)
+			videoV1.POST("/:id/restore", videoController.RestoreVideo)
+			videoV1.POST("/:id/tags", videoController.AddVideoTags)
+			videoV1.DELETE("/:id/tags/:tag", videoController.RemoveVideoTag)
+			videoV1.POST("/:id/share", sharedLinkController.CreateSharedLink)
+			videoV1.GET("/:id/shares", sharedLinkController.ListSharedLinks)
+			videoV1.POST("/:id/signed-url", sharedLinkController.CreateSignedURL)
+		}
+
+		{
+			uploadsV1.Use(controllers.TusMiddleware())
+
+			uploadsV1.OPTIONS("", uploadController.GetUploadOptions)
+			uploadsV1.OPTIONS("/:id", uploadController.GetUploadOptions)
+
+			uploadsV1.Use(controllers.AuthMiddleware())
+
+			uploadsV1.POST("", uploadController.CreateUpload)
+			uploadsV1.HEAD("/:id", uploadController.GetUploadOffset)
+			uploadsV1.PATCH("/:id", uploadController.PatchUpload)
+		}
+
+		{
+			sharesV1.Use(controllers.AuthMiddleware())
+
+			sharesV1.PATCH("/:id", sharedLinkController.UpdateSharedLink)
+			sharesV1.DELETE("/:id", sharedLinkController.RevokeSharedLink)
 		}
 	}
 
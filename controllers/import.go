@@ -0,0 +1,84 @@
+package controllers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/utils"
+	"github.com/3ssalunke/videoverse/workers"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	IMPORT_PATH           = "/api/v1/videos/imports"
+	MAX_IMPORT_URL_LENGTH = 2048
+)
+
+type ImportController struct {
+	importJobRepo repository.ImportJobRepository
+	queue         workers.ImportQueue
+}
+
+func NewImportController(importJobRepo repository.ImportJobRepository, queue workers.ImportQueue) *ImportController {
+	return &ImportController{importJobRepo, queue}
+}
+
+// CreateImport records an import job for the url and queues it. The download runs in
+// the background, its progress is read from GetImport.
+func (i *ImportController) CreateImport(c *gin.Context) {
+	var importReqPayload utils.VideoImportRequest
+
+	if err := c.ShouldBindJSON(&importReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	importURL, err := url.Parse(importReqPayload.URL)
+	if err != nil || (importURL.Scheme != "http" && importURL.Scheme != "https") || importURL.Host == "" ||
+		len(importReqPayload.URL) > MAX_IMPORT_URL_LENGTH {
+		errMessage := fmt.Sprintf("url must be an http or https url of at most %d characters", MAX_IMPORT_URL_LENGTH)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	job := &db.ImportJob{
+		URL:    importURL.String(),
+		Status: db.IMPORT_PENDING,
+	}
+	if err := i.importJobRepo.CreateImportJob(job); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !i.queue.Enqueue(*job) {
+		errMessage := "too many imports in progress, try again later"
+		log.Println("[controller]", errMessage)
+
+		job.Status = db.IMPORT_FAILED
+		job.Error = errMessage
+		if err := i.importJobRepo.UpdateImportJob(job); err != nil {
+			log.Println("[controller] failed to mark import as failed: ", err.Error())
+		}
+
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": errMessage})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("%s/%s", IMPORT_PATH, job.ID))
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+func (i *ImportController) GetImport(c *gin.Context) {
+	job, err := i.importJobRepo.GetImportJobByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
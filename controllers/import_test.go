@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/3ssalunke/videoverse/db"
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeImportQueue struct {
+	full bool
+	jobs []db.ImportJob
+}
+
+func (q *fakeImportQueue) Enqueue(job db.ImportJob) bool {
+	if q.full {
+		return false
+	}
+	q.jobs = append(q.jobs, job)
+	return true
+}
+
+func TestCreateImport_Success(t *testing.T) {
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	mockImportRepo.On("CreateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		job.ID = "job-id"
+		return job.URL == "https://example.com/clip.mp4" && job.Status == db.IMPORT_PENDING
+	})).Return(nil)
+	queue := &fakeImportQueue{}
+
+	importController := NewImportController(mockImportRepo, queue)
+	router := setupRouter("/videos/import", "post", importController.CreateImport)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/import", bytes.NewBuffer([]byte(`{"url": "https://example.com/clip.mp4"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.Equal(t, IMPORT_PATH+"/job-id", w.Header().Get("Location"))
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+	assert.Len(t, queue.jobs, 1)
+	mockImportRepo.AssertExpectations(t)
+}
+
+func TestCreateImport_InvalidURL(t *testing.T) {
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	importController := NewImportController(mockImportRepo, &fakeImportQueue{})
+	router := setupRouter("/videos/import", "post", importController.CreateImport)
+
+	for _, body := range []string{`{}`, `{"url": "ftp://example.com/clip.mp4"}`, `{"url": "/clip.mp4"}`} {
+		req := httptest.NewRequest(http.MethodPost, "/videos/import", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+	mockImportRepo.AssertNotCalled(t, "CreateImportJob", mock.Anything)
+}
+
+func TestCreateImport_QueueFull(t *testing.T) {
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	mockImportRepo.On("CreateImportJob", mock.Anything).Return(nil)
+	mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		return job.Status == db.IMPORT_FAILED
+	})).Return(nil)
+
+	importController := NewImportController(mockImportRepo, &fakeImportQueue{full: true})
+	router := setupRouter("/videos/import", "post", importController.CreateImport)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/import", bytes.NewBuffer([]byte(`{"url": "https://example.com/clip.mp4"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	mockImportRepo.AssertExpectations(t)
+}
+
+func TestGetImport(t *testing.T) {
+	videoID := "video-id"
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	mockImportRepo.On("GetImportJobByID", "job-id").Return(&db.ImportJob{ID: "job-id", Status: db.IMPORT_SUCCEEDED, VideoID: &videoID}, nil)
+	mockImportRepo.On("GetImportJobByID", "missing-id").Return(nil, errors.New("error getting import job by id"))
+
+	importController := NewImportController(mockImportRepo, &fakeImportQueue{})
+	router := setupRouter("/videos/imports/:id", "get", importController.GetImport)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/videos/imports/job-id", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"video_id":"video-id"`)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/videos/imports/missing-id", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
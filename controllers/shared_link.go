@@ -0,0 +1,398 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/services"
+	"github.com/3ssalunke/videoverse/utils"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	SHARED_LINK_PATH                = "/api/v1/shared"
+	SIGNED_URL_PATH                 = "/api/v1/signed"
+	MAX_SHARED_LINK_TTL_HOURS       = 30 * 24
+	MIN_SHARED_LINK_PASSWORD_LENGTH = 8
+	SHARED_LINK_PASSWORD_HEADER     = "X-Share-Password"
+	SHARED_LINK_AUTH_CHALLENGE      = `Basic realm="shared video"`
+)
+
+type sharedLinkResponse struct {
+	ID                string    `json:"id"`
+	VideoID           string    `json:"video_id"`
+	URL               string    `json:"url"`
+	ExpiryDate        time.Time `json:"expiry_date"`
+	Expired           bool      `json:"expired"`
+	PasswordProtected bool      `json:"password_protected"`
+	MaxViews          *int      `json:"max_views"`
+	ViewCount         int       `json:"view_count"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type SharedLinkController struct {
+	videoRepo      repository.VideoRepository
+	sharedLinkRepo repository.SharedLinkRepository
+	fs             utils.FileSystem
+}
+
+func NewSharedLinkController(videoRepo repository.VideoRepository, sharedLinkRepo repository.SharedLinkRepository, fs utils.FileSystem) *SharedLinkController {
+	return &SharedLinkController{videoRepo, sharedLinkRepo, fs}
+}
+
+func (s *SharedLinkController) CreateSharedLink(c *gin.Context) {
+	var shareReqPayload utils.SharedLinkCreateRequest
+
+	if err := c.ShouldBindJSON(&shareReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiryDate, err := resolveExpiryDate(shareReqPayload.TTLSeconds, shareReqPayload.ExpiryDate)
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if shareReqPayload.MaxViews != nil && *shareReqPayload.MaxViews <= 0 {
+		errMessage := "max_views must be positive"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	var passwordHash *string
+	if shareReqPayload.Password != "" {
+		if len(shareReqPayload.Password) < MIN_SHARED_LINK_PASSWORD_LENGTH {
+			errMessage := fmt.Sprintf("password must be at least %d characters long", MIN_SHARED_LINK_PASSWORD_LENGTH)
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+			return
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(shareReqPayload.Password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Println("[controller] failed to hash shared link password:", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid password"})
+			return
+		}
+		hashStr := string(hash)
+		passwordHash = &hashStr
+	}
+
+	video, err := s.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	link := &db.SharedLink{
+		VideoID:      video.ID,
+		ExpiryDate:   expiryDate,
+		PasswordHash: passwordHash,
+		MaxViews:     shareReqPayload.MaxViews,
+	}
+
+	err = s.sharedLinkRepo.CreateSharedLink(link)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":            "shared link created successfully",
+		"link_id":            link.ID,
+		"url":                sharedLinkURL(c, link.ID),
+		"expiry_date":        link.ExpiryDate,
+		"password_protected": link.PasswordHash != nil,
+		"max_views":          link.MaxViews,
+	})
+}
+
+func (s *SharedLinkController) ListSharedLinks(c *gin.Context) {
+	video, err := s.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	links, err := s.sharedLinkRepo.GetSharedLinksByVideoID(video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	sharedLinks := make([]sharedLinkResponse, 0, len(links))
+	for _, link := range links {
+		sharedLinks = append(sharedLinks, sharedLinkResponse{
+			ID:                link.ID,
+			VideoID:           link.VideoID,
+			URL:               sharedLinkURL(c, link.ID),
+			ExpiryDate:        link.ExpiryDate,
+			Expired:           !now.Before(link.ExpiryDate),
+			PasswordProtected: link.PasswordHash != nil,
+			MaxViews:          link.MaxViews,
+			ViewCount:         link.ViewCount,
+			CreatedAt:         link.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"video_id": video.ID, "shared_links": sharedLinks})
+}
+
+func (s *SharedLinkController) UpdateSharedLink(c *gin.Context) {
+	var updateReqPayload utils.SharedLinkUpdateRequest
+
+	if err := c.ShouldBindJSON(&updateReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiryDate, err := resolveExpiryDate(updateReqPayload.TTLSeconds, updateReqPayload.ExpiryDate)
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	link, err := s.sharedLinkRepo.GetSharedLinkByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.sharedLinkRepo.UpdateSharedLinkExpiry(link.ID, expiryDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shared link updated successfully", "link_id": link.ID, "expiry_date": expiryDate})
+}
+
+func (s *SharedLinkController) RevokeSharedLink(c *gin.Context) {
+	link, err := s.sharedLinkRepo.GetSharedLinkByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = s.sharedLinkRepo.DeleteSharedLink(link.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "shared link revoked successfully", "link_id": link.ID})
+}
+
+func (s *SharedLinkController) CreateSignedURL(c *gin.Context) {
+	var signReqPayload utils.SignedURLCreateRequest
+
+	if err := c.ShouldBindJSON(&signReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	expiryDate, err := resolveExpiryDate(signReqPayload.TTLSeconds, signReqPayload.ExpiryDate)
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var byteRange *services.ByteRange
+	if signReqPayload.RangeStart != nil || signReqPayload.RangeEnd != nil {
+		if signReqPayload.RangeStart == nil || signReqPayload.RangeEnd == nil ||
+			*signReqPayload.RangeStart < 0 || *signReqPayload.RangeStart > *signReqPayload.RangeEnd {
+			errMessage := "incorrect byte range bounds"
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+			return
+		}
+		byteRange = &services.ByteRange{Start: *signReqPayload.RangeStart, End: *signReqPayload.RangeEnd}
+	}
+
+	video, err := s.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	storageKey, err := services.StorageKey(video.Path)
+	if err != nil {
+		log.Println("[controller] failed to sign video url:", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign video url"})
+		return
+	}
+
+	query, err := services.SignVideoURL(services.SignedVideo{
+		VideoID:   video.ID,
+		Filename:  storageKey,
+		ExpiresAt: expiryDate,
+		Range:     byteRange,
+	})
+	if errors.Is(err, services.ErrNoSigningKeys) {
+		log.Println("[controller] failed to sign video url:", err.Error())
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "signed urls are disabled"})
+		return
+	}
+	if err != nil {
+		log.Println("[controller] failed to sign video url:", err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign video url"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "signed url created successfully",
+		"url":         fmt.Sprintf("%s?%s", publicURL(c, SIGNED_URL_PATH, video.ID), query.Encode()),
+		"expiry_date": expiryDate,
+	})
+}
+
+// StreamSignedVideo serves a video from a signed url. The signature vouches for
+// the file name, so no database lookup happens on this path.
+func (s *SharedLinkController) StreamSignedVideo(c *gin.Context) {
+	signedVideo, err := services.VerifyVideoURL(c.Param("id"), c.Request.URL.Query())
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		if errors.Is(err, services.ErrSignedURLExpired) {
+			c.JSON(http.StatusGone, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
+		return
+	}
+
+	video := &db.Video{
+		ID:   signedVideo.VideoID,
+		Path: services.StoragePath(signedVideo.Filename),
+	}
+
+	streamVideo(c, s.fs, video, signedVideo.Range)
+}
+
+// resolveExpiryDate accepts either a ttl in seconds or an absolute expiry date,
+// never both, and keeps the result within MAX_SHARED_LINK_TTL_HOURS.
+func resolveExpiryDate(ttlSeconds int64, expiryDate *time.Time) (time.Time, error) {
+	now := time.Now()
+	maxExpiryDate := now.Add(MAX_SHARED_LINK_TTL_HOURS * time.Hour)
+
+	if (ttlSeconds == 0) == (expiryDate == nil) {
+		return time.Time{}, fmt.Errorf("please give either ttl_seconds or expiry_date in request")
+	}
+
+	var expiry time.Time
+	if expiryDate != nil {
+		expiry = *expiryDate
+	} else {
+		if ttlSeconds < 0 {
+			return time.Time{}, fmt.Errorf("ttl_seconds must be positive")
+		}
+		expiry = now.Add(time.Duration(ttlSeconds) * time.Second)
+	}
+
+	if !expiry.After(now) {
+		return time.Time{}, fmt.Errorf("expiry_date must be in the future")
+	}
+	if expiry.After(maxExpiryDate) {
+		return time.Time{}, fmt.Errorf("shared link can not be valid for more than %d hours", MAX_SHARED_LINK_TTL_HOURS)
+	}
+
+	return expiry.UTC(), nil
+}
+
+func sharedLinkURL(c *gin.Context, linkID string) string {
+	return publicURL(c, SHARED_LINK_PATH, linkID)
+}
+
+func publicURL(c *gin.Context, path, id string) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	return fmt.Sprintf("%s://%s%s/%s", scheme, c.Request.Host, path, id)
+}
+
+func (s *SharedLinkController) StreamSharedVideo(c *gin.Context) {
+	link, err := s.sharedLinkRepo.GetSharedLinkByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !time.Now().Before(link.ExpiryDate) {
+		errMessage := "shared link has expired"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusGone, gin.H{"error": errMessage})
+		return
+	}
+
+	if link.PasswordHash != nil {
+		// only taken from headers, query strings end up in access logs and referrers.
+		// Browsers can only send basic auth, the user name is ignored.
+		password := c.GetHeader(SHARED_LINK_PASSWORD_HEADER)
+		if password == "" {
+			_, password, _ = c.Request.BasicAuth()
+		}
+		if password == "" {
+			errMessage := "password required for shared link"
+			log.Println("[controller]", errMessage)
+			c.Header("WWW-Authenticate", SHARED_LINK_AUTH_CHALLENGE)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMessage})
+			return
+		}
+		if bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte(password)) != nil {
+			errMessage := "incorrect password for shared link"
+			log.Println("[controller]", errMessage)
+			c.Header("WWW-Authenticate", SHARED_LINK_AUTH_CHALLENGE)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": errMessage})
+			return
+		}
+	}
+
+	// look the video up first so a link to a trashed video does not use up a view
+	video, err := s.videoRepo.GetVideoByID(link.VideoID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if countsAsView(c.Request) {
+		allowed, err := s.sharedLinkRepo.IncrementSharedLinkViews(link.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if !allowed {
+			errMessage := "shared link view limit reached"
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusForbidden, gin.H{"error": errMessage})
+			return
+		}
+	}
+
+	streamVideo(c, s.fs, video, nil)
+}
+
+// countsAsView reports whether the request starts a new playback. Players fetch
+// a clip with many range requests while seeking, so only plain GETs and ranges
+// from the first byte are counted against max views.
+func countsAsView(req *http.Request) bool {
+	if req.Method != http.MethodGet {
+		return false
+	}
+
+	rangeHeader := req.Header.Get("Range")
+	return rangeHeader == "" || strings.HasPrefix(rangeHeader, "bytes=0-")
+}
@@ -0,0 +1,810 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	"github.com/3ssalunke/videoverse/services"
+	fsMock "github.com/3ssalunke/videoverse/utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+
+// Create shared link
+func TestCreateSharedLink_Success(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Name:     "test.mp4",
+		Path:     "videos/test.mp4",
+		Duration: 30.0,
+		Size:     3000000,
+	}, nil)
+	mockSharedLinkRepo.On("CreateSharedLink", mock.MatchedBy(func(link *db.SharedLink) bool {
+		return link.VideoID == "video-id"
+	})).Run(func(args mock.Arguments) {
+		args.Get(0).(*db.SharedLink).ID = "link-id"
+	}).Return(nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{"ttl_seconds": 3600}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"shared link created successfully"`)
+	assert.Contains(t, w.Body.String(), `"url":"http://example.com/api/v1/shared/link-id"`)
+	mockRepo.AssertExpectations(t)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestCreateSharedLink_WithPasswordAndMaxViews(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id"}, nil)
+	mockSharedLinkRepo.On("CreateSharedLink", mock.MatchedBy(func(link *db.SharedLink) bool {
+		return link.PasswordHash != nil &&
+			bcrypt.CompareHashAndPassword([]byte(*link.PasswordHash), []byte("secret-password")) == nil &&
+			link.MaxViews != nil && *link.MaxViews == 3
+	})).Return(nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{"ttl_seconds": 3600, "password": "secret-password", "max_views": 3}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"password_protected":true`)
+	assert.Contains(t, w.Body.String(), `"max_views":3`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestCreateSharedLink_ShortPassword(t *testing.T) {
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{"ttl_seconds": 3600, "password": "short"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"password must be at least 8 characters long"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSharedLink_MissingExpiry(t *testing.T) {
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"please give either ttl_seconds or expiry_date in request"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSharedLink_ExpiryInPast(t *testing.T) {
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{"expiry_date": "2001-01-01T00:00:00Z"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"expiry_date must be in the future"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCreateSharedLink_VideoNotFoundInDB(t *testing.T) {
+	mockRepo.On("GetVideoByID", "non-existent-video-id").Return(nil, errors.New("error getting video by id"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/non-existent-video-id/share", bytes.NewBuffer([]byte(`{"ttl_seconds": 60}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting video by id"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestCreateSharedLink_DatabaseFailure(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id"}, nil)
+	mockSharedLinkRepo.On("CreateSharedLink", mock.Anything).Return(errors.New("database error"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/share", "post", sharedLinkController.CreateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/share", bytes.NewBuffer([]byte(`{"ttl_seconds": 60}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"database error"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+// Stream shared video
+func TestStreamSharedVideo_Success(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(time.Hour),
+	}, nil)
+	mockSharedLinkRepo.On("IncrementSharedLinkViews", "link-id").Return(true, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockFS.On("Open", "videos/test.mp4").Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "video/mp4", w.Header().Get("Content-Type"))
+	assert.Equal(t, "bytes", w.Header().Get("Accept-Ranges"))
+	assert.NotEmpty(t, w.Header().Get("ETag"))
+	assert.Equal(t, "mock video data", w.Body.String())
+	mockSharedLinkRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSharedVideo_RangeRequest(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(time.Hour),
+	}, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockFS.On("Open", "videos/test.mp4").Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	req.Header.Set("Range", "bytes=5-9")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPartialContent, w.Code)
+	assert.Equal(t, "bytes 5-9/15", w.Header().Get("Content-Range"))
+	assert.Equal(t, "video", w.Body.String())
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSharedVideo_IfRangeMismatch(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(time.Hour),
+	}, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockFS.On("Open", "videos/test.mp4").Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	req.Header.Set("Range", "bytes=5-9")
+	req.Header.Set("If-Range", `"stale-etag"`)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mock video data", w.Body.String())
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSharedVideo_PasswordRequired(t *testing.T) {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	hashStr := string(passwordHash)
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:           "link-id",
+		VideoID:      "video-id",
+		ExpiryDate:   time.Now().Add(time.Hour),
+		PasswordHash: &hashStr,
+	}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"password required for shared link"`)
+	assert.Equal(t, `Basic realm="shared video"`, w.Header().Get("WWW-Authenticate"))
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_IncorrectPassword(t *testing.T) {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	hashStr := string(passwordHash)
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:           "link-id",
+		VideoID:      "video-id",
+		ExpiryDate:   time.Now().Add(time.Hour),
+		PasswordHash: &hashStr,
+	}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	req.Header.Set(SHARED_LINK_PASSWORD_HEADER, "wrong-password")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"incorrect password for shared link"`)
+	assert.Equal(t, `Basic realm="shared video"`, w.Header().Get("WWW-Authenticate"))
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_BasicAuthPassword(t *testing.T) {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	hashStr := string(passwordHash)
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:           "link-id",
+		VideoID:      "video-id",
+		ExpiryDate:   time.Now().Add(time.Hour),
+		PasswordHash: &hashStr,
+	}, nil)
+	mockSharedLinkRepo.On("IncrementSharedLinkViews", "link-id").Return(true, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockFS.On("Open", "videos/test.mp4").Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	req.SetBasicAuth("", "secret-password")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mock video data", w.Body.String())
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSharedVideo_CorrectPassword(t *testing.T) {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	hashStr := string(passwordHash)
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:           "link-id",
+		VideoID:      "video-id",
+		ExpiryDate:   time.Now().Add(time.Hour),
+		PasswordHash: &hashStr,
+	}, nil)
+	mockSharedLinkRepo.On("IncrementSharedLinkViews", "link-id").Return(true, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockFS.On("Open", "videos/test.mp4").Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	req.Header.Set(SHARED_LINK_PASSWORD_HEADER, "secret-password")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "mock video data", w.Body.String())
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSharedVideo_ViewLimitReached(t *testing.T) {
+	maxViews := 1
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(time.Hour),
+		MaxViews:   &maxViews,
+		ViewCount:  1,
+	}, nil)
+	mockSharedLinkRepo.On("IncrementSharedLinkViews", "link-id").Return(false, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"shared link view limit reached"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_PasswordInQueryIgnored(t *testing.T) {
+	passwordHash, _ := bcrypt.GenerateFromPassword([]byte("secret-password"), bcrypt.MinCost)
+	hashStr := string(passwordHash)
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:           "link-id",
+		VideoID:      "video-id",
+		ExpiryDate:   time.Now().Add(time.Hour),
+		PasswordHash: &hashStr,
+	}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id?password=secret-password", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"password required for shared link"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_TrashedVideoKeepsViews(t *testing.T) {
+	maxViews := 1
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(time.Hour),
+		MaxViews:   &maxViews,
+	}, nil)
+	mockRepo.On("GetVideoByID", "video-id").Return(nil, errors.New("video not found"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockSharedLinkRepo.AssertNotCalled(t, "IncrementSharedLinkViews", "link-id")
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_ExpiredLink(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{
+		ID:         "link-id",
+		VideoID:    "video-id",
+		ExpiryDate: time.Now().Add(-time.Hour),
+	}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"shared link has expired"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestStreamSharedVideo_LinkNotFound(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(nil, errors.New("error getting shared link by id"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shared/:id", "get", sharedLinkController.StreamSharedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/shared/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting shared link by id"`)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+// List shared links
+func TestListSharedLinks_Success(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id"}, nil)
+	mockSharedLinkRepo.On("GetSharedLinksByVideoID", "video-id").Return([]db.SharedLink{
+		{ID: "link-id-1", VideoID: "video-id", ExpiryDate: time.Now().Add(time.Hour)},
+		{ID: "link-id-2", VideoID: "video-id", ExpiryDate: time.Now().Add(-time.Hour)},
+	}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/shares", "get", sharedLinkController.ListSharedLinks)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/video-id/shares", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"link-id-1"`)
+	assert.Contains(t, w.Body.String(), `"url":"http://example.com/api/v1/shared/link-id-2"`)
+	assert.Contains(t, w.Body.String(), `"expired":true`)
+	mockRepo.AssertExpectations(t)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestListSharedLinks_VideoNotFoundInDB(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(nil, errors.New("error getting video by id"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/shares", "get", sharedLinkController.ListSharedLinks)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/video-id/shares", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting video by id"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+// Update shared link
+func TestUpdateSharedLink_Success(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{ID: "link-id", VideoID: "video-id"}, nil)
+	mockSharedLinkRepo.On("UpdateSharedLinkExpiry", "link-id", mock.AnythingOfType("time.Time")).Return(nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shares/:id", "patch", sharedLinkController.UpdateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPatch, "/shares/link-id", bytes.NewBuffer([]byte(`{"ttl_seconds": 7200}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"shared link updated successfully"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestUpdateSharedLink_LinkNotFound(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(nil, errors.New("error getting shared link by id"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shares/:id", "patch", sharedLinkController.UpdateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPatch, "/shares/link-id", bytes.NewBuffer([]byte(`{"ttl_seconds": 7200}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting shared link by id"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestUpdateSharedLink_TTLTooLong(t *testing.T) {
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shares/:id", "patch", sharedLinkController.UpdateSharedLink)
+
+	req := httptest.NewRequest(http.MethodPatch, "/shares/link-id", bytes.NewBuffer([]byte(`{"ttl_seconds": 315360000}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"shared link can not be valid for more than 720 hours"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+}
+
+// Revoke shared link
+func TestRevokeSharedLink_Success(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{ID: "link-id", VideoID: "video-id"}, nil)
+	mockSharedLinkRepo.On("DeleteSharedLink", "link-id").Return(nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shares/:id", "delete", sharedLinkController.RevokeSharedLink)
+
+	req := httptest.NewRequest(http.MethodDelete, "/shares/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"shared link revoked successfully"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+func TestRevokeSharedLink_DatabaseFailure(t *testing.T) {
+	mockSharedLinkRepo.On("GetSharedLinkByID", "link-id").Return(&db.SharedLink{ID: "link-id", VideoID: "video-id"}, nil)
+	mockSharedLinkRepo.On("DeleteSharedLink", "link-id").Return(errors.New("error deleting shared link"))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/shares/:id", "delete", sharedLinkController.RevokeSharedLink)
+
+	req := httptest.NewRequest(http.MethodDelete, "/shares/link-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error deleting shared link"`)
+	mockSharedLinkRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockSharedLinkRepo = new(repoMock.MockSharedLinkRepositoryImpl)
+	})
+}
+
+// Signed urls
+func useTestSigningKeys(t *testing.T) {
+	t.Setenv(services.SIGNING_KEYS_ENV, "k1=test-signing-key-0123456789abcdef")
+	t.Setenv(services.SIGNING_KEY_ID_ENV, "k1")
+	if err := services.LoadSigningKeys(); err != nil {
+		t.Fatalf("failed to load signing keys: %v", err)
+	}
+	t.Cleanup(func() {
+		services.SIGNING_KEYS, services.CURRENT_SIGNING_KEY_ID = map[string]string{}, ""
+	})
+}
+
+func TestCreateSignedURL_Success(t *testing.T) {
+	useTestSigningKeys(t)
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "video_store/test.mp4"}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/signed-url", "post", sharedLinkController.CreateSignedURL)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/signed-url", bytes.NewBuffer([]byte(`{"ttl_seconds": 600, "range_start": 0, "range_end": 1023}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"signed url created successfully"`)
+	assert.Contains(t, w.Body.String(), `"url":"http://example.com/api/v1/signed/video-id?`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestCreateSignedURL_Disabled(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "video_store/test.mp4"}, nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/signed-url", "post", sharedLinkController.CreateSignedURL)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/signed-url", bytes.NewBuffer([]byte(`{"ttl_seconds": 600}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"signed urls are disabled"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestCreateSignedURL_InvalidRange(t *testing.T) {
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/videos/:id/signed-url", "post", sharedLinkController.CreateSignedURL)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/signed-url", bytes.NewBuffer([]byte(`{"ttl_seconds": 600, "range_start": 100}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"incorrect byte range bounds"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestStreamSignedVideo_Success(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := services.SignVideoURL(services.SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Range:     &services.ByteRange{Start: 5, End: 9},
+	})
+	mockFS.On("Open", filepath.Join(services.UPLOAD_DIR, "test.mp4")).Return(fsMock.NewMockFile([]byte("mock video data")), nil)
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/signed/:id", "get", sharedLinkController.StreamSignedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/signed/video-id?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "video", w.Body.String())
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestStreamSignedVideo_InvalidSignature(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := services.SignVideoURL(services.SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	query.Set("exp", strconv.FormatInt(time.Now().Add(48*time.Hour).Unix(), 10))
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/signed/:id", "get", sharedLinkController.StreamSignedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/signed/video-id?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid signed url"`)
+}
+
+func TestStreamSignedVideo_Expired(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := services.SignVideoURL(services.SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(-time.Hour),
+	})
+
+	sharedLinkController := NewSharedLinkController(mockRepo, mockSharedLinkRepo, mockFS)
+	router := setupRouter("/signed/:id", "get", sharedLinkController.StreamSignedVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/signed/video-id?"+query.Encode(), nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"signed url has expired"`)
+}
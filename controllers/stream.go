@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/services"
+	"github.com/3ssalunke/videoverse/utils"
+	"github.com/gin-gonic/gin"
+)
+
+var videoContentTypes = map[string]string{
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".mkv":  "video/x-matroska",
+	".webm": "video/webm",
+}
+
+// streamVideo writes the video file to the response. Range, If-Range and
+// conditional requests are handled by http.ServeContent against the ETag set here.
+// A non-nil byteRange limits what is served to that inclusive slice of the file.
+func streamVideo(c *gin.Context, fs utils.FileSystem, video *db.Video, byteRange *services.ByteRange) {
+	file, err := fs.Open(video.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			errMessage := "video file not found"
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusNotFound, gin.H{"error": errMessage})
+			return
+		}
+		errMessage := "failed to open video file"
+		log.Println("[controller]", errMessage, err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMessage})
+		return
+	}
+	defer file.Close()
+
+	fileInfo, err := file.Stat()
+	if err != nil {
+		errMessage := "failed to get video stat"
+		log.Println("[controller]", errMessage, err.Error())
+		c.JSON(http.StatusInternalServerError, gin.H{"error": errMessage})
+		return
+	}
+
+	etag := fmt.Sprintf("%s-%x-%x", video.ID, fileInfo.Size(), fileInfo.ModTime().UnixNano())
+	var content io.ReadSeeker = file
+	if byteRange != nil {
+		if byteRange.Start >= fileInfo.Size() {
+			errMessage := "byte range is outside of the video file"
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusRequestedRangeNotSatisfiable, gin.H{"error": errMessage})
+			return
+		}
+		end := min(byteRange.End, fileInfo.Size()-1)
+		content = io.NewSectionReader(file, byteRange.Start, end-byteRange.Start+1)
+		etag = fmt.Sprintf("%s-%x-%x", etag, byteRange.Start, end)
+	}
+
+	if contentType, ok := videoContentTypes[strings.ToLower(filepath.Ext(video.Path))]; ok {
+		c.Header("Content-Type", contentType)
+	}
+	c.Header("ETag", fmt.Sprintf(`"%s"`, etag))
+
+	http.ServeContent(c.Writer, c.Request, filepath.Base(video.Path), fileInfo.ModTime(), content)
+}
@@ -0,0 +1,227 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/services"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	UPLOAD_PATH             = "/api/v1/uploads"
+	TUS_OFFSET_CONTENT_TYPE = "application/offset+octet-stream"
+	MAX_UPLOAD_SIZE_BYTES   = services.MAX_VIDEO_SIZE_MB * 1024 * 1024
+)
+
+// UploadController implements the tus 1.0 core protocol and creation extension, so
+// large videos can be uploaded in chunks and resumed after a dropped connection.
+type UploadController struct {
+	videoRepo repository.VideoRepository
+	store     *services.TusStore
+}
+
+func NewUploadController(videoRepo repository.VideoRepository, store *services.TusStore) *UploadController {
+	return &UploadController{videoRepo, store}
+}
+
+// TusMiddleware answers every request with the protocol version and rejects clients
+// speaking another one. OPTIONS is exempt, it is how clients discover the version.
+func TusMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", services.TUS_VERSION)
+		if c.Request.Method != http.MethodOptions && c.GetHeader("Tus-Resumable") != services.TUS_VERSION {
+			c.Header("Tus-Version", services.TUS_VERSION)
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported tus version"})
+			return
+		}
+		c.Next()
+	}
+}
+
+func (u *UploadController) GetUploadOptions(c *gin.Context) {
+	c.Header("Tus-Version", services.TUS_VERSION)
+	c.Header("Tus-Extension", services.TUS_EXTENSIONS)
+	c.Header("Tus-Max-Size", strconv.Itoa(MAX_UPLOAD_SIZE_BYTES))
+	c.Status(http.StatusNoContent)
+}
+
+func (u *UploadController) CreateUpload(c *gin.Context) {
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		errMessage := "Upload-Length must be a positive integer"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+	if length > MAX_UPLOAD_SIZE_BYTES {
+		errMessage := fmt.Sprintf("file size exceeds the maximum allowed size of %d MB", services.MAX_VIDEO_SIZE_MB)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errMessage})
+		return
+	}
+
+	metadata, err := services.ParseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filename := filepath.Base(metadata["filename"])
+	if metadata["filename"] == "" || filename == "." || filename == string(filepath.Separator) {
+		errMessage := "Upload-Metadata must contain a filename"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+	metadata["filename"] = filename
+
+	upload, err := u.store.Create(length, metadata)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Location", fmt.Sprintf("%s/%s", UPLOAD_PATH, upload.ID))
+	c.Status(http.StatusCreated)
+}
+
+func (u *UploadController) GetUploadOffset(c *gin.Context) {
+	upload, err := u.store.Get(c.Param("id"))
+	if err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		log.Println("[controller] failed to get upload: ", err.Error())
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload appends a chunk to the upload. Once the last byte has arrived the file
+// goes through the same validation as a direct upload and becomes a video, whose id
+// is returned in the X-Video-Id header. X-Video-Duplicate is set when the content
+// matched an existing video, which is returned instead. If that step fails for a
+// reason other than validation the upload is kept, and a PATCH with an empty body
+// at the final offset retries it.
+func (u *UploadController) PatchUpload(c *gin.Context) {
+	id := c.Param("id")
+
+	if c.ContentType() != TUS_OFFSET_CONTENT_TYPE {
+		errMessage := fmt.Sprintf("Content-Type must be %s", TUS_OFFSET_CONTENT_TYPE)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": errMessage})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		errMessage := "Upload-Offset must be a non-negative integer"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	unlock, ok := u.store.Lock(id)
+	if !ok {
+		errMessage := "upload is being written by another request"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusLocked, gin.H{"error": errMessage})
+		return
+	}
+	defer unlock()
+
+	upload, err := u.store.Get(id)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if c.Request.ContentLength > upload.Length-offset {
+		errMessage := "chunk exceeds the upload length"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": errMessage})
+		return
+	}
+
+	upload, err = u.store.WriteChunk(id, offset, c.Request.Body)
+	if err != nil {
+		if errors.Is(err, services.ErrUploadOffsetMismatch) {
+			c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to write upload chunk"})
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if !upload.Complete() {
+		c.Status(http.StatusNoContent)
+		return
+	}
+
+	video, duplicate, status, err := u.finalizeUpload(upload)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("X-Video-Id", video.ID)
+	if duplicate {
+		c.Header("X-Video-Duplicate", "true")
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// finalizeUpload adopts the completed data file as the staged video, so it is renamed
+// into the store rather than copied. The chunks arrive over separate requests, so the
+// file is hashed here in one read instead of while it streams.
+func (u *UploadController) finalizeUpload(upload *services.TusUpload) (*db.Video, bool, int, error) {
+	staged := &services.StagedVideo{
+		Path:     u.store.DataPath(upload.ID),
+		Filename: upload.Metadata["filename"],
+		Size:     upload.Length,
+	}
+
+	sha256, err := services.HashFile(staged.Path)
+	if err != nil {
+		log.Println("[controller] failed to hash completed upload: ", err.Error())
+		return nil, false, http.StatusInternalServerError, errors.New("failed to hash completed upload")
+	}
+	staged.SHA256 = sha256
+
+	video, duplicate, status, err := saveUploadedVideo(u.videoRepo, staged)
+	if err != nil {
+		// a file that failed validation will fail it again, there is nothing to resume
+		if status == http.StatusBadRequest {
+			u.removeUpload(upload.ID)
+		}
+		return nil, false, status, err
+	}
+
+	u.removeUpload(upload.ID)
+	return video, duplicate, http.StatusOK, nil
+}
+
+func (u *UploadController) removeUpload(id string) {
+	if err := u.store.Remove(id); err != nil {
+		log.Println("[controller] failed to remove upload: ", err.Error())
+	}
+}
@@ -0,0 +1,217 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/3ssalunke/videoverse/db"
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	"github.com/3ssalunke/videoverse/services"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func setupUploadRouter(uploadController *UploadController) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	uploads := r.Group(UPLOAD_PATH)
+	uploads.Use(TusMiddleware())
+	uploads.OPTIONS("", uploadController.GetUploadOptions)
+	uploads.POST("", uploadController.CreateUpload)
+	uploads.HEAD("/:id", uploadController.GetUploadOffset)
+	uploads.PATCH("/:id", uploadController.PatchUpload)
+	return r
+}
+
+func tusRequest(method, target string, body []byte, headers map[string]string) *http.Request {
+	req := httptest.NewRequest(method, target, bytes.NewReader(body))
+	req.Header.Set("Tus-Resumable", services.TUS_VERSION)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+	return req
+}
+
+func createTusUpload(t *testing.T, router *gin.Engine, length string) string {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodPost, UPLOAD_PATH, nil, map[string]string{
+		"Upload-Length":   length,
+		"Upload-Metadata": "filename Y2xpcC5tcDQ=",
+	}))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	return w.Header().Get("Location")
+}
+
+func patchTusUpload(router *gin.Engine, location, offset string, chunk []byte) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodPatch, location, chunk, map[string]string{
+		"Content-Type":  TUS_OFFSET_CONTENT_TYPE,
+		"Upload-Offset": offset,
+	}))
+	return w
+}
+
+func TestUploadOptions(t *testing.T) {
+	router := setupUploadRouter(NewUploadController(new(repoMock.MockVideoRepositoryImpl), services.NewTusStore(t.TempDir())))
+
+	req := httptest.NewRequest(http.MethodOptions, UPLOAD_PATH, nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, services.TUS_VERSION, w.Header().Get("Tus-Version"))
+	assert.Equal(t, "creation", w.Header().Get("Tus-Extension"))
+}
+
+func TestUpload_RejectsOtherTusVersion(t *testing.T) {
+	router := setupUploadRouter(NewUploadController(new(repoMock.MockVideoRepositoryImpl), services.NewTusStore(t.TempDir())))
+
+	req := httptest.NewRequest(http.MethodPost, UPLOAD_PATH, nil)
+	req.Header.Set("Tus-Resumable", "0.2.2")
+	req.Header.Set("Upload-Length", "10")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+}
+
+func TestCreateUpload_InvalidRequest(t *testing.T) {
+	router := setupUploadRouter(NewUploadController(new(repoMock.MockVideoRepositoryImpl), services.NewTusStore(t.TempDir())))
+
+	cases := []struct {
+		headers map[string]string
+		status  int
+	}{
+		{map[string]string{"Upload-Metadata": "filename Y2xpcC5tcDQ="}, http.StatusBadRequest},
+		{map[string]string{"Upload-Length": "10"}, http.StatusBadRequest},
+		{map[string]string{"Upload-Length": "999999999999", "Upload-Metadata": "filename Y2xpcC5tcDQ="}, http.StatusRequestEntityTooLarge},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, tusRequest(http.MethodPost, UPLOAD_PATH, nil, tc.headers))
+		assert.Equal(t, tc.status, w.Code, tc.headers)
+	}
+}
+
+func TestUpload_ResumesAndCreatesVideo(t *testing.T) {
+	videoRepo := new(repoMock.MockVideoRepositoryImpl)
+	// sha256 of "helloworld"
+	videoRepo.On("GetVideoBySHA256", "936a185caaa266bb9cbe981e9e05cb78cd732b0b3280eb944412bb6f8f8f07af").Return(nil, nil)
+	videoRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		video.ID = "video-id"
+		return video.Title == "clip" && video.Size == 10
+	})).Return(nil)
+
+	var uploadedData []byte
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		return &services.VideoMeta{FileSize: video.Size, FileDuration: 30}, nil
+	}
+	services.UploadVideo = func(video *services.StagedVideo) (*services.UploadedVideo, error) {
+		uploadedData, _ = os.ReadFile(video.Path)
+		return &services.UploadedVideo{Filename: "1-" + video.Filename, FilePath: "video_store/1-" + video.Filename}, nil
+	}
+
+	store := services.NewTusStore(t.TempDir())
+	router := setupUploadRouter(NewUploadController(videoRepo, store))
+
+	location := createTusUpload(t, router, "10")
+	assert.True(t, strings.HasPrefix(location, UPLOAD_PATH+"/"))
+
+	w := patchTusUpload(router, location, "0", []byte("hello"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	w = patchTusUpload(router, location, "0", []byte("hello"))
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodHead, location, nil, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "10", w.Header().Get("Upload-Length"))
+
+	w = patchTusUpload(router, location, "5", []byte("world"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Upload-Offset"))
+	assert.Equal(t, "video-id", w.Header().Get("X-Video-Id"))
+	assert.Equal(t, "helloworld", string(uploadedData))
+	videoRepo.AssertExpectations(t)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodHead, location, nil, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpload_FailedValidationDiscardsUpload(t *testing.T) {
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		return nil, errors.New("video duration must be between 5 and 50 seconds")
+	}
+
+	videoRepo := new(repoMock.MockVideoRepositoryImpl)
+	videoRepo.On("GetVideoBySHA256", mock.Anything).Return(nil, nil)
+	router := setupUploadRouter(NewUploadController(videoRepo, services.NewTusStore(t.TempDir())))
+
+	location := createTusUpload(t, router, "5")
+
+	w := patchTusUpload(router, location, "0", []byte("hello"))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "video duration must be between 5 and 50 seconds")
+	videoRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodHead, location, nil, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestUpload_PatchRejectsWrongContentType(t *testing.T) {
+	router := setupUploadRouter(NewUploadController(new(repoMock.MockVideoRepositoryImpl), services.NewTusStore(t.TempDir())))
+
+	location := createTusUpload(t, router, "5")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodPatch, location, []byte("hello"), map[string]string{
+		"Content-Type":  "application/octet-stream",
+		"Upload-Offset": "0",
+	}))
+
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+}
+
+func TestUpload_PatchRejectsOversizedChunk(t *testing.T) {
+	router := setupUploadRouter(NewUploadController(new(repoMock.MockVideoRepositoryImpl), services.NewTusStore(t.TempDir())))
+
+	location := createTusUpload(t, router, "5")
+
+	w := patchTusUpload(router, location, "0", []byte("hello world"))
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+}
+
+func TestUpload_DuplicateReturnsExistingVideo(t *testing.T) {
+	videoRepo := new(repoMock.MockVideoRepositoryImpl)
+	videoRepo.On("GetVideoBySHA256", mock.AnythingOfType("string")).Return(&db.Video{ID: "existing-id"}, nil)
+
+	store := services.NewTusStore(t.TempDir())
+	router := setupUploadRouter(NewUploadController(videoRepo, store))
+
+	location := createTusUpload(t, router, "5")
+
+	w := patchTusUpload(router, location, "0", []byte("hello"))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "existing-id", w.Header().Get("X-Video-Id"))
+	assert.Equal(t, "true", w.Header().Get("X-Video-Duplicate"))
+	videoRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, tusRequest(http.MethodHead, location, nil, nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
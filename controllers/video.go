@@ -1,21 +1,50 @@
 package controllers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/3ssalunke/videoverse/db"
 	"github.com/3ssalunke/videoverse/repository"
 	"github.com/3ssalunke/videoverse/services"
 	"github.com/3ssalunke/videoverse/utils"
+	"github.com/3ssalunke/videoverse/workers"
 	"github.com/gin-gonic/gin"
 )
 
+const (
+	DEFAULT_VIDEO_PAGE_SIZE      = 20
+	MAX_VIDEO_PAGE_SIZE          = 100
+	MAX_VIDEO_TITLE_LENGTH       = 200
+	MAX_VIDEO_DESCRIPTION_LENGTH = 5000
+	MAX_METADATA_ENTRIES         = 50
+	MAX_METADATA_KEY_LENGTH      = 64
+	MAX_METADATA_VALUE_LENGTH    = 1024
+	MAX_TAG_LENGTH               = 50
+	MAX_TAGS_PER_REQUEST         = 20
+	MAX_SEARCH_QUERY_LENGTH      = 200
+	MAX_VIDEOS_PER_UPLOAD        = 100
+	MAX_MERGE_DIMENSION          = 7680
+	MAX_MERGE_FRAME_RATE         = 120
+	MIN_MERGE_SAMPLE_RATE        = 8000
+	MAX_MERGE_SAMPLE_RATE        = 192000
+)
+
+var (
+	metadataKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	tagPattern         = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+)
+
 type VideoController struct {
 	videoRepo repository.VideoRepository
 	fs        utils.FileSystem
@@ -25,42 +54,151 @@ func NewVideoController(videoRepo repository.VideoRepository, fs utils.FileSyste
 	return &VideoController{videoRepo, fs}
 }
 
+// videoUploadResult is the outcome for one file of an upload with several videos.
+type videoUploadResult struct {
+	Filename  string `json:"filename"`
+	Status    int    `json:"status"`
+	VideoID   string `json:"video_id,omitempty"`
+	Duplicate bool   `json:"duplicate"`
+	Error     string `json:"error,omitempty"`
+}
+
+// UploadVideo stores every "video" part of the form, each validated on its own, so
+// one bad file does not fail the others. With a single file the response is the
+// same as it has always been. With several it lists a result per file, and is a 207
+// when only some of them were stored.
 func (v *VideoController) UploadVideo(c *gin.Context) {
-	file, header, err := c.Request.FormFile("video")
+	reader, err := c.Request.MultipartReader()
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	videoMeta, err := services.ValidateVideo(file, header)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var results []videoUploadResult
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the rest of the body can not be read, report it against the next file
+			log.Println("[controller] failed to read upload:", err.Error())
+			results = append(results, videoUploadResult{Status: http.StatusBadRequest, Error: err.Error()})
+			break
+		}
+		if part.FormName() != "video" || part.FileName() == "" {
+			part.Close()
+			continue
+		}
+
+		if len(results) == MAX_VIDEOS_PER_UPLOAD {
+			errMessage := fmt.Sprintf("can not upload more than %d videos in one request", MAX_VIDEOS_PER_UPLOAD)
+			results = append(results, videoUploadResult{Filename: part.FileName(), Status: http.StatusBadRequest, Error: errMessage})
+			part.Close()
+			break
+		}
+
+		results = append(results, v.uploadVideoPart(part))
+		part.Close()
+	}
+
+	if len(results) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": http.ErrMissingFile.Error()})
 		return
 	}
 
-	uploadedFile, err := services.UploadVideo(file, header)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if len(results) == 1 {
+		result := results[0]
+		if result.Error != "" {
+			c.JSON(result.Status, gin.H{"error": result.Error})
+			return
+		}
+		if result.Duplicate {
+			c.JSON(http.StatusOK, gin.H{"message": "video already exists", "video_id": result.VideoID, "duplicate": true})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "video uploaded succesfully", "video_id": result.VideoID, "duplicate": false})
 		return
 	}
-	defer file.Close()
 
-	video := &db.Video{
-		Name:     uploadedFile.Filename,
-		Path:     uploadedFile.FilePath,
-		Duration: videoMeta.FileDuration,
-		Size:     videoMeta.FileSize,
+	c.JSON(batchUploadStatus(results), gin.H{"results": results})
+}
+
+func (v *VideoController) uploadVideoPart(part *multipart.Part) videoUploadResult {
+	result := videoUploadResult{Filename: part.FileName()}
+
+	staged, err := services.StageVideo(part, part.FileName())
+	if err != nil {
+		result.Status, result.Error = stageErrorStatus(err), err.Error()
+		return result
 	}
+	defer services.DiscardStagedVideo(staged)
 
-	err = v.videoRepo.CreateVideo(video)
+	video, duplicate, status, err := saveUploadedVideo(v.videoRepo, staged)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		return
+		result.Status, result.Error = status, err.Error()
+		return result
+	}
+
+	result.Status, result.VideoID, result.Duplicate = http.StatusOK, video.ID, duplicate
+	return result
+}
+
+// stageErrorStatus tells a file the client should not have sent apart from the
+// server failing to stage it.
+func stageErrorStatus(err error) int {
+	var invalid *services.InvalidVideoError
+	if errors.Is(err, services.ErrVideoTooLarge) || errors.As(err, &invalid) {
+		return http.StatusBadRequest
+	}
+	return http.StatusInternalServerError
+}
+
+// batchUploadStatus is 200 when every file was stored and 207 when some were. When
+// none were it is 400, unless one of the failures was the server's.
+func batchUploadStatus(results []videoUploadResult) int {
+	succeeded, serverFailure := 0, false
+	for _, result := range results {
+		if result.Error == "" {
+			succeeded++
+		} else if result.Status >= http.StatusInternalServerError {
+			serverFailure = true
+		}
+	}
+
+	switch {
+	case succeeded == len(results):
+		return http.StatusOK
+	case succeeded > 0:
+		return http.StatusMultiStatus
+	case serverFailure:
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
+}
+
+// saveUploadedVideo ingests the staged file and also returns the status code to
+// answer with on failure. duplicate is set when an existing video with the same
+// content was returned instead of a new one.
+func saveUploadedVideo(videoRepo repository.VideoRepository, staged *services.StagedVideo) (*db.Video, bool, int, error) {
+	video, duplicate, err := workers.IngestStagedVideo(videoRepo, staged)
+	if err != nil {
+		var rejected *workers.RejectedVideoError
+		if errors.As(err, &rejected) {
+			return nil, false, http.StatusBadRequest, err
+		}
+		return nil, false, http.StatusInternalServerError, err
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "video uploaded succesfully", "video_id": video.ID})
+	return video, duplicate, http.StatusOK, nil
 }
 
+// TrimVideo cuts a new video out of an existing one. mode "fast", the default, copies
+// the streams and cuts on keyframes, "accurate" re-encodes and cuts on the exact
+// frames, and "smart" cuts on the exact frames re-encoding only around the cuts.
+// Accurate trims take an optional codec and crf, smart trims only the crf. Either
+// way the new video gets the duration probed from the output.
 func (v *VideoController) TrimVideo(c *gin.Context) {
 	var trimReqPayload utils.VideoTrimRequest
 
@@ -69,6 +207,22 @@ func (v *VideoController) TrimVideo(c *gin.Context) {
 		return
 	}
 
+	if trimReqPayload.Mode == "" {
+		trimReqPayload.Mode = services.TRIM_MODE_FAST
+	}
+	if trimReqPayload.Mode != services.TRIM_MODE_FAST && trimReqPayload.Mode != services.TRIM_MODE_ACCURATE &&
+		trimReqPayload.Mode != services.TRIM_MODE_SMART {
+		errMessage := fmt.Sprintf("mode must be one of %s, %s or %s", services.TRIM_MODE_FAST, services.TRIM_MODE_ACCURATE, services.TRIM_MODE_SMART)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+	if err := validateTrimEncoding(&trimReqPayload); err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	video, err := v.videoRepo.GetVideoByID(trimReqPayload.VideoID)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -92,12 +246,16 @@ func (v *VideoController) TrimVideo(c *gin.Context) {
 		return
 	}
 
-	currTimestamp := time.Now().UnixMilli()
-	parts := strings.Split(video.Name, "-")
-	trimmedFilename := fmt.Sprintf("%d-%s", currTimestamp, strings.Join(parts[1:], "-"))
-	outputPath := filepath.Join(services.UPLOAD_DIR, trimmedFilename)
+	// a stream copy keeps the container of its source, anything re-encoded is written
+	// to the container of TRIM_ENCODING
+	ext := filepath.Ext(video.Path)
+	if trimReqPayload.Mode != services.TRIM_MODE_FAST {
+		ext = services.TRIM_ENCODING.Extension
+	}
+	outputPath := services.NewStoragePath(ext)
 
-	if err := services.TrimVideo(video.Path, outputPath, trimReqPayload.StartTS, trimReqPayload.EndTS, video.Duration); err != nil {
+	trimOptions := services.TrimOptions{Mode: trimReqPayload.Mode, VideoCodec: trimReqPayload.Codec, CRF: trimReqPayload.CRF}
+	if err := services.TrimVideo(video.Path, outputPath, trimReqPayload.StartTS, trimReqPayload.EndTS, trimOptions); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to trim video"})
 		return
 	}
@@ -110,11 +268,28 @@ func (v *VideoController) TrimVideo(c *gin.Context) {
 		return
 	}
 
+	trimmedMeta, err := services.ProbeVideo(outputPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to probe trimmed video"})
+		return
+	}
+
 	video = &db.Video{
-		Name:     trimmedFilename,
-		Path:     outputPath,
-		Duration: trimReqPayload.EndTS - trimReqPayload.StartTS,
-		Size:     fileInfo.Size(),
+		Name:             filepath.Base(outputPath),
+		OriginalFilename: video.OriginalFilename,
+		Path:             outputPath,
+		Duration:         trimmedMeta.FileDuration,
+		Size:             fileInfo.Size(),
+		Title:            video.Title,
+		Description:      video.Description,
+		Metadata:         mergeMetadata([]db.Video{*video}),
+		Media:            trimmedMeta.Media,
+		Sources: []db.VideoDerivation{{
+			SourceVideoID: video.ID,
+			Operation:     db.DERIVATION_TRIM,
+			StartTS:       &trimReqPayload.StartTS,
+			EndTS:         &trimReqPayload.EndTS,
+		}},
 	}
 
 	err = v.videoRepo.CreateVideo(video)
@@ -126,6 +301,9 @@ func (v *VideoController) TrimVideo(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "video trimmed successfully", "video_id": video.ID})
 }
 
+// MergeVideos joins videos in the requested order. Videos that differ in codec, size,
+// frame rate or audio layout are normalised to target first, whose missing fields
+// are taken from the first video.
 func (v *VideoController) MergeVideos(c *gin.Context) {
 	var mergeReqPayload utils.VideosMergeRequest
 
@@ -141,6 +319,16 @@ func (v *VideoController) MergeVideos(c *gin.Context) {
 		return
 	}
 
+	var target services.MergeTarget
+	if mergeReqPayload.Target != nil {
+		if err := validateMergeTarget(mergeReqPayload.Target); err != nil {
+			log.Println("[controller]", err.Error())
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		target = services.MergeTarget(*mergeReqPayload.Target)
+	}
+
 	videos, err := v.videoRepo.GetVideosByIDs(mergeReqPayload.VideoIDs)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -154,8 +342,16 @@ func (v *VideoController) MergeVideos(c *gin.Context) {
 		return
 	}
 
+	// the database returns the videos in any order, merge them in the requested one
+	videosByID := make(map[string]db.Video, len(videos))
+	for _, video := range videos {
+		videosByID[video.ID] = video
+	}
+	for i, id := range mergeReqPayload.VideoIDs {
+		videos[i] = videosByID[id]
+	}
+
 	var videoFilepaths []string
-	mergedVideoDuration := 0.0
 
 	for _, video := range videos {
 		_, err = v.fs.Stat(video.Path)
@@ -166,14 +362,11 @@ func (v *VideoController) MergeVideos(c *gin.Context) {
 			return
 		}
 		videoFilepaths = append(videoFilepaths, video.Path)
-		mergedVideoDuration += video.Duration
 	}
 
-	currTimestamp := time.Now().UnixMilli()
-	mergedFilename := fmt.Sprintf("%d-merged.mp4", currTimestamp)
-	outputPath := filepath.Join(services.UPLOAD_DIR, mergedFilename)
+	outputPath := services.NewStoragePath(services.MERGE_ENCODING.Extension)
 
-	if err := services.MergeVideos(videoFilepaths, outputPath); err != nil {
+	if err := services.MergeVideos(videoFilepaths, outputPath, target); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to merge videos"})
 		return
 	}
@@ -186,11 +379,39 @@ func (v *VideoController) MergeVideos(c *gin.Context) {
 		return
 	}
 
+	mergedMeta, err := services.ProbeVideo(outputPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to probe merged video"})
+		return
+	}
+
+	var titles, descriptions []string
+	for _, video := range videos {
+		if video.Title != "" {
+			titles = append(titles, video.Title)
+		}
+		if video.Description != "" {
+			descriptions = append(descriptions, video.Description)
+		}
+	}
+
 	video := &db.Video{
-		Name:     mergedFilename,
-		Path:     outputPath,
-		Duration: mergedVideoDuration,
-		Size:     fileInfo.Size(),
+		Name:        filepath.Base(outputPath),
+		Path:        outputPath,
+		Duration:    mergedMeta.FileDuration,
+		Size:        fileInfo.Size(),
+		Title:       truncate(strings.Join(titles, " + "), MAX_VIDEO_TITLE_LENGTH),
+		Description: truncate(strings.Join(descriptions, "\n\n"), MAX_VIDEO_DESCRIPTION_LENGTH),
+		Metadata:    mergeMetadata(videos),
+		Media:       mergedMeta.Media,
+		Sources:     make([]db.VideoDerivation, len(videos)),
+	}
+	for i, source := range videos {
+		video.Sources[i] = db.VideoDerivation{
+			SourceVideoID: source.ID,
+			Operation:     db.DERIVATION_MERGE,
+			Position:      i,
+		}
 	}
 
 	err = v.videoRepo.CreateVideo(video)
@@ -201,3 +422,484 @@ func (v *VideoController) MergeVideos(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "videos merged successfully", "video_id": video.ID})
 }
+
+func (v *VideoController) GetVideo(c *gin.Context) {
+	video, err := v.videoRepo.GetVideoDetailsByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if video.SharedLinks == nil {
+		video.SharedLinks = []db.SharedLink{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"video": video})
+}
+
+// GetVideoLineage returns the trims and merges the video was made from, back to the
+// raw uploads, and everything that was made from it.
+func (v *VideoController) GetVideoLineage(c *gin.Context) {
+	id := c.Param("id")
+
+	lineage, err := v.videoRepo.GetVideoLineage(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	known := false
+	for _, video := range lineage.Videos {
+		if video.ID == id {
+			known = true
+			break
+		}
+	}
+	if !known && len(lineage.Ancestors) == 0 && len(lineage.Descendants) == 0 {
+		errMessage := "video not found"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusNotFound, gin.H{"error": errMessage})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"video_id": id, "lineage": lineage})
+}
+
+// DeleteVideo moves the video to the trash, or removes it for good together with its
+// file and shared links when called with permanent=true.
+func (v *VideoController) DeleteVideo(c *gin.Context) {
+	if c.Query("permanent") == "true" {
+		v.deleteVideoPermanently(c)
+		return
+	}
+
+	video, err := v.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.TrashVideo(video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "video moved to trash", "video_id": video.ID})
+}
+
+func (v *VideoController) deleteVideoPermanently(c *gin.Context) {
+	video, err := v.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		video, err = v.videoRepo.GetTrashedVideoByID(c.Param("id"))
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.DeleteVideo(video, func() error {
+		return utils.RemoveIfExists(v.fs, video.Path)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "video deleted successfully", "video_id": video.ID})
+}
+
+func (v *VideoController) ListTrash(c *gin.Context) {
+	videos, err := v.videoRepo.GetTrashedVideos(time.Now(), MAX_VIDEO_PAGE_SIZE)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if videos == nil {
+		videos = []db.Video{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"videos": videos})
+}
+
+func (v *VideoController) RestoreVideo(c *gin.Context) {
+	video, err := v.videoRepo.GetTrashedVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.RestoreVideo(video.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "video restored successfully", "video_id": video.ID})
+}
+
+func (v *VideoController) EmptyTrash(c *gin.Context) {
+	purged, err := workers.PurgeTrashedVideos(v.videoRepo, v.fs, time.Now(), workers.TRASH_JANITOR_BATCH)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error(), "purged": purged})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "trash emptied successfully", "purged": purged})
+}
+
+// UpdateVideo edits the title, description and metadata of a video. Metadata keys
+// sent with a null value are removed, the others are added or overwritten.
+func (v *VideoController) UpdateVideo(c *gin.Context) {
+	var updateReqPayload utils.VideoUpdateRequest
+
+	if err := c.ShouldBindJSON(&updateReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video, err := v.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	if updateReqPayload.Title != nil {
+		video.Title = strings.TrimSpace(*updateReqPayload.Title)
+		// only a title that is being set must not be empty, older videos have none
+		if video.Title == "" {
+			errMessage := "title can not be empty"
+			log.Println("[controller]", errMessage)
+			c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+			return
+		}
+	}
+	if updateReqPayload.Description != nil {
+		video.Description = strings.TrimSpace(*updateReqPayload.Description)
+	}
+	if video.Metadata == nil {
+		video.Metadata = db.Metadata{}
+	}
+	for key, value := range updateReqPayload.Metadata {
+		if value == nil {
+			delete(video.Metadata, key)
+			continue
+		}
+		video.Metadata[key] = *value
+	}
+
+	if err := validateVideoDetails(video); err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.UpdateVideoDetails(video)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "video updated successfully", "video": video})
+}
+
+func (v *VideoController) ListVideos(c *gin.Context) {
+	var listReqPayload utils.VideoListRequest
+
+	if err := c.ShouldBindQuery(&listReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	params := repository.VideoListParams{
+		MinDuration:            listReqPayload.MinDuration,
+		MaxDuration:            listReqPayload.MaxDuration,
+		MinSize:                listReqPayload.MinSize,
+		MaxSize:                listReqPayload.MaxSize,
+		CreatedAfter:           listReqPayload.CreatedAfter,
+		CreatedBefore:          listReqPayload.CreatedBefore,
+		OriginalFilenamePrefix: listReqPayload.OriginalFilenamePrefix,
+		SortBy:                 listReqPayload.Sort,
+		Limit:                  listReqPayload.Limit,
+		Cursor:                 listReqPayload.Cursor,
+	}
+
+	var err error
+	if params.TagsAny, err = normalizeTags(listReqPayload.TagsAny); err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if params.TagsAll, err = normalizeTags(listReqPayload.TagsAll); err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if params.SortBy == "" {
+		params.SortBy = "created_at"
+	}
+	if params.SortBy != "created_at" && params.SortBy != "duration" && params.SortBy != "size" {
+		errMessage := "sort must be one of created_at, duration or size"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	switch listReqPayload.Order {
+	case "", "desc":
+		params.SortDesc = true
+	case "asc":
+		params.SortDesc = false
+	default:
+		errMessage := "order must be either asc or desc"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	if params.Limit == 0 {
+		params.Limit = DEFAULT_VIDEO_PAGE_SIZE
+	}
+	if params.Limit < 0 || params.Limit > MAX_VIDEO_PAGE_SIZE {
+		errMessage := fmt.Sprintf("limit must be between 1 and %d", MAX_VIDEO_PAGE_SIZE)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	videos, nextCursor, err := v.videoRepo.ListVideos(params)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if videos == nil {
+		videos = []db.Video{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"videos": videos, "next_cursor": nextCursor})
+}
+
+func (v *VideoController) SearchVideos(c *gin.Context) {
+	var searchReqPayload utils.VideoSearchRequest
+
+	if err := c.ShouldBindQuery(&searchReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	query := strings.TrimSpace(searchReqPayload.Query)
+	if query == "" || len(query) > MAX_SEARCH_QUERY_LENGTH {
+		errMessage := fmt.Sprintf("q must be between 1 and %d characters", MAX_SEARCH_QUERY_LENGTH)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	limit := searchReqPayload.Limit
+	if limit == 0 {
+		limit = DEFAULT_VIDEO_PAGE_SIZE
+	}
+	if limit < 0 || limit > MAX_VIDEO_PAGE_SIZE {
+		errMessage := fmt.Sprintf("limit must be between 1 and %d", MAX_VIDEO_PAGE_SIZE)
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+	if searchReqPayload.Offset < 0 {
+		errMessage := "offset can not be negative"
+		log.Println("[controller]", errMessage)
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMessage})
+		return
+	}
+
+	results, err := v.videoRepo.SearchVideos(query, limit, searchReqPayload.Offset)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	if results == nil {
+		results = []repository.VideoSearchResult{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+func (v *VideoController) AddVideoTags(c *gin.Context) {
+	var tagsReqPayload utils.VideoTagsRequest
+
+	if err := c.ShouldBindJSON(&tagsReqPayload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tags, err := normalizeTags(tagsReqPayload.Tags)
+	if err == nil && len(tags) == 0 {
+		err = fmt.Errorf("please give at least one tag in request")
+	}
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video, err := v.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.AddVideoTags(video, tags)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tags added successfully", "video_id": video.ID, "tags": tags})
+}
+
+func (v *VideoController) RemoveVideoTag(c *gin.Context) {
+	tags, err := normalizeTags([]string{c.Param("tag")})
+	if err == nil && len(tags) != 1 {
+		err = fmt.Errorf("please give exactly one tag to remove")
+	}
+	if err != nil {
+		log.Println("[controller]", err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	video, err := v.videoRepo.GetVideoByID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = v.videoRepo.RemoveVideoTag(video, tags[0])
+	if err != nil {
+		if errors.Is(err, repository.ErrTagNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag removed successfully", "video_id": video.ID, "tag": tags[0]})
+}
+
+// normalizeTags lowercases, trims and de-duplicates tags. Each entry may itself be
+// a comma separated list, so both ?tags_any=a&tags_any=b and ?tags_any=a,b work.
+func normalizeTags(raw []string) ([]string, error) {
+	seen := map[string]bool{}
+	var tags []string
+	for _, entry := range raw {
+		for _, tag := range strings.Split(entry, ",") {
+			tag = strings.ToLower(strings.TrimSpace(tag))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if len(tag) > MAX_TAG_LENGTH || !tagPattern.MatchString(tag) {
+				return nil, fmt.Errorf("tag %q must be 1 to %d lowercase letters, digits, '_' or '-'", tag, MAX_TAG_LENGTH)
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+
+	if len(tags) > MAX_TAGS_PER_REQUEST {
+		return nil, fmt.Errorf("can not give more than %d tags in request", MAX_TAGS_PER_REQUEST)
+	}
+
+	return tags, nil
+}
+
+func validateVideoDetails(video *db.Video) error {
+	if utf8.RuneCountInString(video.Title) > MAX_VIDEO_TITLE_LENGTH {
+		return fmt.Errorf("title can not be longer than %d characters", MAX_VIDEO_TITLE_LENGTH)
+	}
+	if utf8.RuneCountInString(video.Description) > MAX_VIDEO_DESCRIPTION_LENGTH {
+		return fmt.Errorf("description can not be longer than %d characters", MAX_VIDEO_DESCRIPTION_LENGTH)
+	}
+	if len(video.Metadata) > MAX_METADATA_ENTRIES {
+		return fmt.Errorf("metadata can not have more than %d entries", MAX_METADATA_ENTRIES)
+	}
+	for key, value := range video.Metadata {
+		if len(key) > MAX_METADATA_KEY_LENGTH || !metadataKeyPattern.MatchString(key) {
+			return fmt.Errorf("metadata key %q must be 1 to %d letters, digits, '_', '.' or '-'", key, MAX_METADATA_KEY_LENGTH)
+		}
+		if utf8.RuneCountInString(value) > MAX_METADATA_VALUE_LENGTH {
+			return fmt.Errorf("metadata value for %q can not be longer than %d characters", key, MAX_METADATA_VALUE_LENGTH)
+		}
+	}
+
+	return nil
+}
+
+// validateTrimEncoding checks the codec and crf against the mode and their allowlist.
+func validateTrimEncoding(trimReq *utils.VideoTrimRequest) error {
+	if trimReq.Mode == services.TRIM_MODE_FAST && (trimReq.Codec != "" || trimReq.CRF != nil) {
+		return fmt.Errorf("codec and crf only apply to %s and %s trims", services.TRIM_MODE_ACCURATE, services.TRIM_MODE_SMART)
+	}
+	if trimReq.Mode == services.TRIM_MODE_SMART && trimReq.Codec != "" {
+		return fmt.Errorf("%s trims keep the codec of the source", services.TRIM_MODE_SMART)
+	}
+	if _, ok := services.TRIM_VIDEO_CODECS[trimReq.Codec]; trimReq.Codec != "" && !ok {
+		return fmt.Errorf("codec must be one of h264 or hevc")
+	}
+	if trimReq.CRF != nil && (*trimReq.CRF < services.MIN_TRIM_CRF || *trimReq.CRF > services.MAX_TRIM_CRF) {
+		return fmt.Errorf("crf must be between %d and %d", services.MIN_TRIM_CRF, services.MAX_TRIM_CRF)
+	}
+
+	return nil
+}
+
+// validateMergeTarget checks the fields that were given, zero fields are left to
+// the first video.
+func validateMergeTarget(target *utils.VideoMergeTarget) error {
+	for _, dimension := range []int{target.Width, target.Height} {
+		if dimension != 0 && (dimension < 2 || dimension > MAX_MERGE_DIMENSION) {
+			return fmt.Errorf("target width and height must be between 2 and %d", MAX_MERGE_DIMENSION)
+		}
+	}
+	if target.FrameRate != 0 && (target.FrameRate < 1 || target.FrameRate > MAX_MERGE_FRAME_RATE) {
+		return fmt.Errorf("target frame_rate must be between 1 and %d", MAX_MERGE_FRAME_RATE)
+	}
+	if target.SampleRate != 0 && (target.SampleRate < MIN_MERGE_SAMPLE_RATE || target.SampleRate > MAX_MERGE_SAMPLE_RATE) {
+		return fmt.Errorf("target sample_rate must be between %d and %d", MIN_MERGE_SAMPLE_RATE, MAX_MERGE_SAMPLE_RATE)
+	}
+	if _, ok := services.MERGE_CHANNEL_LAYOUTS[target.Channels]; target.Channels != 0 && !ok {
+		return fmt.Errorf("target channels must be one of 1, 2, 6 or 8")
+	}
+
+	return nil
+}
+
+// mergeMetadata combines the metadata of the source videos in order, so later
+// videos win on conflicting keys.
+func mergeMetadata(videos []db.Video) db.Metadata {
+	metadata := db.Metadata{}
+	for _, video := range videos {
+		for key, value := range video.Metadata {
+			metadata[key] = value
+		}
+	}
+
+	return metadata
+}
+
+func truncate(value string, maxLength int) string {
+	runes := []rune(value)
+	if len(runes) <= maxLength {
+		return value
+	}
+
+	return string(runes[:maxLength])
+}
@@ -9,9 +9,12 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
 	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
 	"github.com/3ssalunke/videoverse/services"
 	"github.com/3ssalunke/videoverse/utils"
@@ -24,8 +27,15 @@ import (
 func setupRouter(route, method string, handlers ...gin.HandlerFunc) *gin.Engine {
 	gin.SetMode(gin.TestMode)
 	r := gin.New()
-	if method == "post" {
+	switch method {
+	case "get":
+		r.GET(route, handlers...)
+	case "post":
 		r.POST(route, handlers...)
+	case "patch":
+		r.PATCH(route, handlers...)
+	case "delete":
+		r.DELETE(route, handlers...)
 	}
 	return r
 }
@@ -34,15 +44,28 @@ var mockRepo = new(repoMock.MockVideoRepositoryImpl)
 var mockFS = new(fsMock.MockFileSystem)
 
 // Upload video
-var mockUploadVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.UploadedVideo, error) {
+var mockStageVideo = func(file io.Reader, filename string) (*services.StagedVideo, error) {
+	io.Copy(io.Discard, file)
+	return &services.StagedVideo{Path: "/mock/path/staged", Filename: filename, Size: 15}, nil
+}
+
+var mockUploadVideo = func(video *services.StagedVideo) (*services.UploadedVideo, error) {
 	return &services.UploadedVideo{Filename: "test.mp4", FilePath: "/mock/path/test.mp4"}, nil
 }
 
-var mockValidateVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.VideoMeta, error) {
+var mockProbeVideo = func(filePath string) (*services.VideoMeta, error) {
+	return &services.VideoMeta{
+		FileDuration: 39.96,
+		Media:        db.VideoMedia{Container: "mp4", VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 30, StreamCount: 2},
+	}, nil
+}
+
+var mockValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
 	return &services.VideoMeta{FileSize: 1024, FileDuration: 30}, nil
 }
 
 func TestUploadVideo_Success(t *testing.T) {
+	services.StageVideo = mockStageVideo
 	mockRepo.On("CreateVideo", mock.Anything).Return(nil)
 
 	services.UploadVideo = mockUploadVideo
@@ -92,7 +115,8 @@ func TestUploadVideo_MissingFile(t *testing.T) {
 }
 
 func TestUploadVideo_InvalidVideoFile(t *testing.T) {
-	services.ValidateVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.VideoMeta, error) {
+	services.StageVideo = mockStageVideo
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
 		return nil, errors.New("invalid video file")
 	}
 
@@ -120,11 +144,42 @@ func TestUploadVideo_InvalidVideoFile(t *testing.T) {
 	})
 }
 
+func TestUploadVideo_NotAVideo(t *testing.T) {
+	services.StageVideo = func(file io.Reader, filename string) (*services.StagedVideo, error) {
+		return nil, &services.InvalidVideoError{Reason: "container avi is not allowed, allowed containers are mp4, mov, mkv, webm"}
+	}
+
+	videoController := NewVideoController(mockRepo, mockFS)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fileWriter, _ := writer.CreateFormFile("video", "clip.mp4")
+	io.Copy(fileWriter, bytes.NewReader([]byte("RIFF\x00\x00\x00\x00AVI LIST")))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	router := setupRouter("/upload", "post", videoController.UploadVideo)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"error":"container avi is not allowed, allowed containers are mp4, mov, mkv, webm"}`)
+	mockRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
 func TestUploadVideo_UploadServiceFailure(t *testing.T) {
-	services.UploadVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.UploadedVideo, error) {
+	services.StageVideo = mockStageVideo
+	services.UploadVideo = func(video *services.StagedVideo) (*services.UploadedVideo, error) {
 		return nil, errors.New("video upload failed")
 	}
-	services.ValidateVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.VideoMeta, error) {
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
 		return &services.VideoMeta{FileSize: 1024, FileDuration: 30}, nil
 	}
 
@@ -153,13 +208,14 @@ func TestUploadVideo_UploadServiceFailure(t *testing.T) {
 }
 
 func TestUploadVideo_DatabaseFailure(t *testing.T) {
+	services.StageVideo = mockStageVideo
 	mockRepo.On("CreateVideo", mock.Anything).Return(errors.New("database error"))
 
-	services.UploadVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.UploadedVideo, error) {
+	services.UploadVideo = func(video *services.StagedVideo) (*services.UploadedVideo, error) {
 		return &services.UploadedVideo{Filename: "test.mp4", FilePath: "/mock/path/test.mp4"}, nil
 	}
 
-	services.ValidateVideo = func(video multipart.File, fileHeader *multipart.FileHeader) (*services.VideoMeta, error) {
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
 		return &services.VideoMeta{FileSize: 1024, FileDuration: 30}, nil
 	}
 
@@ -188,23 +244,185 @@ func TestUploadVideo_DatabaseFailure(t *testing.T) {
 	})
 }
 
+func TestUploadVideo_DuplicateContent(t *testing.T) {
+	services.StageVideo = func(file io.Reader, filename string) (*services.StagedVideo, error) {
+		io.Copy(io.Discard, file)
+		return &services.StagedVideo{Path: "/mock/path/staged", Filename: filename, Size: 15, SHA256: "abc123"}, nil
+	}
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		t.Fatal("duplicate upload should not be validated")
+		return nil, nil
+	}
+	mockRepo.On("GetVideoBySHA256", "abc123").Return(&db.Video{ID: "existing-id"}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	fileWriter, _ := writer.CreateFormFile("video", "test.mp4")
+	io.Copy(fileWriter, bytes.NewReader([]byte("mock video data")))
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	w := httptest.NewRecorder()
+
+	router := setupRouter("/upload", "post", videoController.UploadVideo)
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"video_id":"existing-id"`)
+	assert.Contains(t, w.Body.String(), `"duplicate":true`)
+	mockRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+		services.ValidateVideo = mockValidateVideo
+	})
+}
+
+func batchUploadRequest(filenames ...string) *http.Request {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, filename := range filenames {
+		fileWriter, _ := writer.CreateFormFile("video", filename)
+		io.Copy(fileWriter, bytes.NewReader([]byte("mock video data")))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestUploadVideo_Batch(t *testing.T) {
+	services.StageVideo = mockStageVideo
+	services.UploadVideo = mockUploadVideo
+	services.ValidateVideo = mockValidateVideo
+	mockRepo.On("CreateVideo", mock.Anything).Run(func(args mock.Arguments) {
+		video := args.Get(0).(*db.Video)
+		video.ID = "id-" + video.OriginalFilename
+	}).Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/upload", "post", videoController.UploadVideo)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, batchUploadRequest("a.mp4", "b.mp4"))
+
+	var response struct {
+		Results []videoUploadResult `json:"results"`
+	}
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []videoUploadResult{
+		{Filename: "a.mp4", Status: http.StatusOK, VideoID: "id-a.mp4"},
+		{Filename: "b.mp4", Status: http.StatusOK, VideoID: "id-b.mp4"},
+	}, response.Results)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestUploadVideo_BatchPartialFailure(t *testing.T) {
+	services.StageVideo = func(file io.Reader, filename string) (*services.StagedVideo, error) {
+		io.Copy(io.Discard, file)
+		if filename == "notes.mp4" {
+			return nil, &services.InvalidVideoError{Reason: "file is not a recognised video container, allowed containers are mp4, mov, mkv, webm"}
+		}
+		return &services.StagedVideo{Path: "/mock/path/staged", Filename: filename, Size: 15}, nil
+	}
+	services.UploadVideo = mockUploadVideo
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		if video.Filename == "short.mp4" {
+			return nil, errors.New("video duration must be between 5 and 50 seconds")
+		}
+		return mockValidateVideo(video)
+	}
+	mockRepo.On("CreateVideo", mock.Anything).Run(func(args mock.Arguments) {
+		video := args.Get(0).(*db.Video)
+		video.ID = "id-" + video.OriginalFilename
+	}).Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/upload", "post", videoController.UploadVideo)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, batchUploadRequest("a.mp4", "notes.mp4", "short.mp4", "d.mp4"))
+
+	var response struct {
+		Results []videoUploadResult `json:"results"`
+	}
+	assert.Equal(t, http.StatusMultiStatus, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, []videoUploadResult{
+		{Filename: "a.mp4", Status: http.StatusOK, VideoID: "id-a.mp4"},
+		{Filename: "notes.mp4", Status: http.StatusBadRequest, Error: "file is not a recognised video container, allowed containers are mp4, mov, mkv, webm"},
+		{Filename: "short.mp4", Status: http.StatusBadRequest, Error: "video duration must be between 5 and 50 seconds"},
+		{Filename: "d.mp4", Status: http.StatusOK, VideoID: "id-d.mp4"},
+	}, response.Results)
+	mockRepo.AssertNumberOfCalls(t, "CreateVideo", 2)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+		services.ValidateVideo = mockValidateVideo
+	})
+}
+
+func TestUploadVideo_BatchAllRejected(t *testing.T) {
+	services.StageVideo = mockStageVideo
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		return nil, errors.New("invalid video file")
+	}
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/upload", "post", videoController.UploadVideo)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, batchUploadRequest("a.mp4", "b.mp4"))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"results":[`)
+	mockRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+		services.ValidateVideo = mockValidateVideo
+	})
+}
+
 // Trim video
 func TestTrimVideo_Success(t *testing.T) {
 	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
-		ID:       "video-id",
-		Name:     "test.mp4",
-		Path:     "videos/test.mp4",
-		Duration: 120.0,
-		Size:     3000000,
+		ID:               "video-id",
+		Name:             "test.mp4",
+		OriginalFilename: "holiday-final.mp4",
+		Path:             "videos/test.mp4",
+		Duration:         120.0,
+		Size:             3000000,
 	}, nil)
-	mockRepo.On("CreateVideo", mock.Anything).Return(nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		// the trimmed file is named by the server, the client's name is only carried along
+		return strings.HasPrefix(video.Path, services.UPLOAD_DIR) &&
+			!strings.Contains(video.Path, "holiday") &&
+			filepath.Ext(video.Path) == ".mp4" &&
+			video.Name == filepath.Base(video.Path) &&
+			video.OriginalFilename == "holiday-final.mp4" &&
+			video.Media.VideoCodec == "h264" && video.Media.Width == 1920
+	})).Return(nil)
 
 	mockFileInfo := fsMock.MockFileInfo{FileSize: 2000000}
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(mockFileInfo, nil)
 
-	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs, duration float64) error {
+	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options services.TrimOptions) error {
 		return nil
 	}
+	services.ProbeVideo = mockProbeVideo
 
 	videoController := NewVideoController(mockRepo, mockFS)
 	router := setupRouter("/trim", "post", videoController.TrimVideo)
@@ -232,6 +450,165 @@ func TestTrimVideo_Success(t *testing.T) {
 	})
 }
 
+func TestTrimVideo_AccurateMode(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Path:     "videos/test.mkv",
+		Duration: 120.0,
+	}, nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		// the duration is what the output turned out to be, not end_ts - start_ts
+		return video.Duration == 39.96 && filepath.Ext(video.Path) == services.TRIM_ENCODING.Extension
+	})).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{FileSize: 2000000}, nil)
+
+	var trimMode string
+	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options services.TrimOptions) error {
+		trimMode = options.Mode
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	jsonVal, _ := json.Marshal(utils.VideoTrimRequest{VideoID: "video-id", StartTS: 10, EndTS: 50, Mode: "accurate"})
+	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer(jsonVal))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.TRIM_MODE_ACCURATE, trimMode)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestTrimVideo_SmartMode(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Path:     "videos/test.mkv",
+		Duration: 120.0,
+	}, nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		// the duration is what the output turned out to be, not end_ts - start_ts
+		return video.Duration == 39.96 && filepath.Ext(video.Path) == services.TRIM_ENCODING.Extension
+	})).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{FileSize: 2000000}, nil)
+
+	var trimMode string
+	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options services.TrimOptions) error {
+		trimMode = options.Mode
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	jsonVal, _ := json.Marshal(utils.VideoTrimRequest{VideoID: "video-id", StartTS: 10, EndTS: 50, Mode: "smart"})
+	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer(jsonVal))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.TRIM_MODE_SMART, trimMode)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestTrimVideo_InvalidMode(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	jsonVal, _ := json.Marshal(utils.VideoTrimRequest{VideoID: "video-id", StartTS: 10, EndTS: 50, Mode: "smooth"})
+	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer(jsonVal))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"error":"mode must be one of fast, accurate or smart"}`)
+	mockRepo.AssertNotCalled(t, "GetVideoByID", mock.Anything)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestTrimVideo_CodecAndCRF(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Path:     "videos/test.mkv",
+		Duration: 120.0,
+	}, nil)
+	mockRepo.On("CreateVideo", mock.AnythingOfType("*db.Video")).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{FileSize: 2000000}, nil)
+
+	var trimOptions services.TrimOptions
+	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options services.TrimOptions) error {
+		trimOptions = options
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer([]byte(`{"video_id": "video-id", "start_ts": 10, "end_ts": 50, "mode": "accurate", "codec": "hevc", "crf": 28}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "hevc", trimOptions.VideoCodec)
+	if assert.NotNil(t, trimOptions.CRF) {
+		assert.Equal(t, 28, *trimOptions.CRF)
+	}
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestTrimVideo_InvalidEncoding(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	for body, errMessage := range map[string]string{
+		`{"video_id": "video-id", "start_ts": 10, "end_ts": 50, "crf": 20}`:                          "codec and crf only apply to accurate and smart trims",
+		`{"video_id": "video-id", "start_ts": 10, "end_ts": 50, "mode": "smart", "codec": "h264"}`:   "smart trims keep the codec of the source",
+		`{"video_id": "video-id", "start_ts": 10, "end_ts": 50, "mode": "accurate", "codec": "vp9"}`: "codec must be one of h264 or hevc",
+		`{"video_id": "video-id", "start_ts": 10, "end_ts": 50, "mode": "accurate", "crf": 52}`:      "crf must be between 0 and 51",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), errMessage, body)
+	}
+	mockRepo.AssertNotCalled(t, "GetVideoByID", mock.Anything)
+}
+
 func TestTrimVideo_InvalidJSON(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer([]byte(`{invalid-json}`)))
 	req.Header.Set("Content-Type", "application/json")
@@ -369,9 +746,10 @@ func TestMergeVideos_Success(t *testing.T) {
 	mockFileInfo := fsMock.MockFileInfo{}
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(mockFileInfo, nil)
 
-	services.MergeVideos = func(videoPaths []string, outputPath string) error {
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
 		return nil
 	}
+	services.ProbeVideo = mockProbeVideo
 
 	videoController := NewVideoController(mockRepo, mockFS)
 	router := setupRouter("/merge", "post", videoController.MergeVideos)
@@ -397,6 +775,69 @@ func TestMergeVideos_Success(t *testing.T) {
 	})
 }
 
+func TestMergeVideos_PassesTarget(t *testing.T) {
+	mockRepo.On("GetVideosByIDs", []string{"video-id-1", "video-id-2"}).Return([]db.Video{
+		{ID: "video-id-1", Path: "videos/test-1.mp4"},
+		{ID: "video-id-2", Path: "videos/test-2.mov"},
+	}, nil)
+	mockRepo.On("CreateVideo", mock.Anything).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{}, nil)
+
+	var mergeTarget services.MergeTarget
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
+		mergeTarget = target
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/merge", "post", videoController.MergeVideos)
+
+	req := httptest.NewRequest(http.MethodPost, "/merge", strings.NewReader(
+		`{"video_ids":["video-id-1","video-id-2"],"target":{"width":1280,"height":720,"frame_rate":30}}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, services.MergeTarget{Width: 1280, Height: 720, FrameRate: 30}, mergeTarget)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestMergeVideos_InvalidTarget(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/merge", "post", videoController.MergeVideos)
+
+	for body, errMessage := range map[string]string{
+		`{"width":1}`:          "target width and height must be between 2 and 7680",
+		`{"height":10000}`:     "target width and height must be between 2 and 7680",
+		`{"frame_rate":240}`:   "target frame_rate must be between 1 and 120",
+		`{"sample_rate":1000}`: "target sample_rate must be between 8000 and 192000",
+		`{"channels":3}`:       "target channels must be one of 1, 2, 6 or 8",
+	} {
+		req := httptest.NewRequest(http.MethodPost, "/merge", strings.NewReader(
+			`{"video_ids":["video-id-1","video-id-2"],"target":`+body+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), `{"error":"`+errMessage+`"}`, body)
+	}
+	mockRepo.AssertNotCalled(t, "GetVideosByIDs", mock.Anything)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
 func TestMergeVideos_InvalidJSON(t *testing.T) {
 	req := httptest.NewRequest(http.MethodPost, "/merge", bytes.NewBuffer([]byte(`{invalid-json}`)))
 	req.Header.Set("Content-Type", "application/json")
@@ -531,7 +972,7 @@ func TestMergeVideos_MergeServiceFailure(t *testing.T) {
 	mockFileInfo := fsMock.MockFileInfo{}
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(mockFileInfo, nil)
 
-	services.MergeVideos = func(videoPaths []string, outputPath string) error {
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
 		return errors.New("merge failed")
 	}
 
@@ -581,9 +1022,10 @@ func TestMergeVideos_MergedVideoFileStatFailure(t *testing.T) {
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(mockFileInfo, nil).Times(2)
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(nil, errors.New("file not found")).Once()
 
-	services.MergeVideos = func(videoPaths []string, outputPath string) error {
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
 		return nil
 	}
+	services.ProbeVideo = mockProbeVideo
 
 	videoController := NewVideoController(mockRepo, mockFS)
 	router := setupRouter("/merge", "post", videoController.MergeVideos)
@@ -631,9 +1073,10 @@ func TestMergeVideos_CreateDBCallFailure(t *testing.T) {
 	mockFileInfo := fsMock.MockFileInfo{}
 	mockFS.On("Stat", mock.AnythingOfType("string")).Return(mockFileInfo, nil)
 
-	services.MergeVideos = func(videoPaths []string, outputPath string) error {
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
 		return nil
 	}
+	services.ProbeVideo = mockProbeVideo
 
 	videoController := NewVideoController(mockRepo, mockFS)
 	router := setupRouter("/merge", "post", videoController.MergeVideos)
@@ -658,3 +1101,835 @@ func TestMergeVideos_CreateDBCallFailure(t *testing.T) {
 		mockFS = new(fsMock.MockFileSystem)
 	})
 }
+
+// List videos
+func TestListVideos_Success(t *testing.T) {
+	minDuration := 10.0
+	mockRepo.On("ListVideos", repository.VideoListParams{
+		MinDuration:            &minDuration,
+		OriginalFilenamePrefix: "clip",
+		SortBy:                 "duration",
+		SortDesc:               false,
+		Limit:                  2,
+	}).Return([]db.Video{
+		{ID: "video-id-1", OriginalFilename: "clip-1.mp4", Path: "videos/clip-1.mp4", Duration: 12.0},
+		{ID: "video-id-2", OriginalFilename: "clip-2.mp4", Path: "videos/clip-2.mp4", Duration: 20.0},
+	}, "next-cursor", nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos?min_duration=10&original_filename_prefix=clip&sort=duration&order=asc&limit=2", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"video-id-1"`)
+	assert.Contains(t, w.Body.String(), `"next_cursor":"next-cursor"`)
+	assert.NotContains(t, w.Body.String(), `videos/clip-1.mp4`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestListVideos_Defaults(t *testing.T) {
+	mockRepo.On("ListVideos", repository.VideoListParams{
+		SortBy:   "created_at",
+		SortDesc: true,
+		Limit:    DEFAULT_VIDEO_PAGE_SIZE,
+	}).Return(nil, "", nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"videos": [], "next_cursor": ""}`, w.Body.String())
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestListVideos_InvalidSort(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos?sort=name", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"sort must be one of created_at, duration or size"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListVideos_LimitTooLarge(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos?limit=1000", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"limit must be between 1 and 100"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestListVideos_InvalidCursor(t *testing.T) {
+	mockRepo.On("ListVideos", mock.Anything).Return(nil, "", repository.ErrInvalidCursor)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos?cursor=garbage", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"invalid cursor"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+// Get video
+func TestGetVideo_Success(t *testing.T) {
+	mockRepo.On("GetVideoDetailsByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Name:     "test.mp4",
+		Path:     "videos/test.mp4",
+		Duration: 30.0,
+		Size:     3000000,
+		SharedLinks: []db.SharedLink{
+			{ID: "link-id", VideoID: "video-id"},
+		},
+	}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "get", videoController.GetVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/video-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"id":"video-id"`)
+	assert.Contains(t, w.Body.String(), `"duration":30`)
+	assert.Contains(t, w.Body.String(), `"shared_links":[{"id":"link-id"`)
+	assert.NotContains(t, w.Body.String(), `videos/test.mp4`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestGetVideo_VideoNotFoundInDB(t *testing.T) {
+	mockRepo.On("GetVideoDetailsByID", "video-id").Return(nil, errors.New("error getting video details by id"))
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "get", videoController.GetVideo)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/video-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting video details by id"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+// Delete video
+func TestDeleteVideo_MovesToTrash(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Path: "videos/test.mp4"}, nil)
+	mockRepo.On("TrashVideo", "video-id").Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video moved to trash"`)
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestDeleteVideo_Permanently(t *testing.T) {
+	video := &db.Video{ID: "video-id", Name: "test.mp4", Path: "videos/test.mp4"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("DeleteVideo", video).Return(nil)
+	mockFS.On("Remove", "videos/test.mp4").Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id?permanent=true", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video deleted successfully"`)
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestDeleteVideo_FileAlreadyMissing(t *testing.T) {
+	video := &db.Video{ID: "video-id", Name: "test.mp4", Path: "videos/test.mp4"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("DeleteVideo", video).Return(nil)
+	mockFS.On("Remove", "videos/test.mp4").Return(os.ErrNotExist)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id?permanent=true", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video deleted successfully"`)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestDeleteVideo_FileRemoveFailure(t *testing.T) {
+	video := &db.Video{ID: "video-id", Name: "test.mp4", Path: "videos/test.mp4"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("DeleteVideo", video).Return(nil)
+	mockFS.On("Remove", "videos/test.mp4").Return(os.ErrPermission)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id?permanent=true", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"error"`)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestDeleteVideo_PermanentlyFromTrash(t *testing.T) {
+	video := &db.Video{ID: "video-id", Name: "test.mp4", Path: "videos/test.mp4"}
+	mockRepo.On("GetVideoByID", "video-id").Return(nil, errors.New("error getting video by id"))
+	mockRepo.On("GetTrashedVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("DeleteVideo", video).Return(nil)
+	mockFS.On("Remove", "videos/test.mp4").Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id?permanent=true", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video deleted successfully"`)
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestDeleteVideo_VideoNotFoundInDB(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(nil, errors.New("error getting video by id"))
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "delete", videoController.DeleteVideo)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting video by id"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+// Trash
+func TestRestoreVideo_Success(t *testing.T) {
+	mockRepo.On("GetTrashedVideoByID", "video-id").Return(&db.Video{ID: "video-id"}, nil)
+	mockRepo.On("RestoreVideo", "video-id").Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/restore", "post", videoController.RestoreVideo)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/restore", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video restored successfully"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestRestoreVideo_NotInTrash(t *testing.T) {
+	mockRepo.On("GetTrashedVideoByID", "video-id").Return(nil, errors.New("error getting trashed video by id"))
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/restore", "post", videoController.RestoreVideo)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/restore", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"error getting trashed video by id"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestEmptyTrash_Success(t *testing.T) {
+	trashed := []db.Video{
+		{ID: "video-id-1", Path: "videos/test-1.mp4"},
+		{ID: "video-id-2", Path: "videos/test-2.mp4"},
+	}
+	mockRepo.On("GetTrashedVideos", mock.AnythingOfType("time.Time"), mock.AnythingOfType("int")).Return(trashed, nil)
+	mockRepo.On("DeleteVideo", mock.Anything).Return(nil)
+	mockFS.On("Remove", "videos/test-1.mp4").Return(nil)
+	mockFS.On("Remove", "videos/test-2.mp4").Return(os.ErrNotExist)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/trash", "delete", videoController.EmptyTrash)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/trash", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"purged":2`)
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+// Update video
+func TestUpdateVideo_Success(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{
+		ID:       "video-id",
+		Title:    "old title",
+		Metadata: db.Metadata{"campaign": "spring", "draft": "yes"},
+	}, nil)
+	mockRepo.On("UpdateVideoDetails", mock.MatchedBy(func(video *db.Video) bool {
+		return video.Title == "new title" &&
+			video.Description == "final cut" &&
+			video.Metadata["campaign"] == "summer" &&
+			video.Metadata["client"] == "acme" &&
+			len(video.Metadata) == 2
+	})).Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "patch", videoController.UpdateVideo)
+
+	body := `{"title": " new title ", "description": "final cut", "metadata": {"campaign": "summer", "client": "acme", "draft": null}}`
+	req := httptest.NewRequest(http.MethodPatch, "/videos/video-id", bytes.NewBuffer([]byte(body)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"video updated successfully"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestUpdateVideo_EmptyTitle(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Title: "old title"}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "patch", videoController.UpdateVideo)
+
+	req := httptest.NewRequest(http.MethodPatch, "/videos/video-id", bytes.NewBuffer([]byte(`{"title": "  "}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"title can not be empty"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestUpdateVideo_MetadataOnlyWithoutTitle(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Title: ""}, nil)
+	mockRepo.On("UpdateVideoDetails", mock.MatchedBy(func(video *db.Video) bool {
+		return video.Title == "" && video.Metadata["campaign"] == "spring"
+	})).Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "patch", videoController.UpdateVideo)
+
+	req := httptest.NewRequest(http.MethodPatch, "/videos/video-id", bytes.NewBuffer([]byte(`{"metadata": {"campaign": "spring"}}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestUpdateVideo_InvalidMetadataKey(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Title: "old title"}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id", "patch", videoController.UpdateVideo)
+
+	req := httptest.NewRequest(http.MethodPatch, "/videos/video-id", bytes.NewBuffer([]byte(`{"metadata": {"bad key": "value"}}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `metadata key \"bad key\" must be`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestMergeVideos_CombinesMetadata(t *testing.T) {
+	mockRepo.On("GetVideosByIDs", []string{"video-id-1", "video-id-2"}).Return([]db.Video{
+		{
+			ID:          "video-id-1",
+			Path:        "videos/test-1.mp4",
+			Title:       "intro",
+			Description: "opening shot",
+			Metadata:    db.Metadata{"campaign": "spring", "camera": "a"},
+		},
+		{
+			ID:       "video-id-2",
+			Path:     "videos/test-2.mp4",
+			Title:    "outro",
+			Metadata: db.Metadata{"camera": "b"},
+		},
+	}, nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		return video.Title == "intro + outro" &&
+			video.Description == "opening shot" &&
+			video.Metadata["campaign"] == "spring" &&
+			video.Metadata["camera"] == "b"
+	})).Return(nil)
+
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{}, nil)
+
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/merge", "post", videoController.MergeVideos)
+
+	jsonVal, _ := json.Marshal(utils.VideosMergeRequest{VideoIDs: []string{"video-id-1", "video-id-2"}})
+
+	req := httptest.NewRequest(http.MethodPost, "/merge", bytes.NewBuffer(jsonVal))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+// Tags
+func TestListVideos_TagFilters(t *testing.T) {
+	mockRepo.On("ListVideos", repository.VideoListParams{
+		TagsAny:  []string{"spring", "summer"},
+		TagsAll:  []string{"approved", "final"},
+		SortBy:   "created_at",
+		SortDesc: true,
+		Limit:    DEFAULT_VIDEO_PAGE_SIZE,
+	}).Return([]db.Video{}, "", nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos", "get", videoController.ListVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos?tags_any=Spring,summer&tags_all=approved&tags_all=final", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestAddVideoTags_Success(t *testing.T) {
+	video := &db.Video{ID: "video-id"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("AddVideoTags", video, []string{"campaign-a", "b-roll"}).Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/tags", "post", videoController.AddVideoTags)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/tags", bytes.NewBuffer([]byte(`{"tags": ["Campaign-A", "b-roll", "campaign-a"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"tags":["campaign-a","b-roll"]`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestAddVideoTags_InvalidTag(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/tags", "post", videoController.AddVideoTags)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/tags", bytes.NewBuffer([]byte(`{"tags": ["no spaces"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `tag \"no spaces\" must be`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestAddVideoTags_EmptyTags(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/tags", "post", videoController.AddVideoTags)
+
+	req := httptest.NewRequest(http.MethodPost, "/videos/video-id/tags", bytes.NewBuffer([]byte(`{"tags": []}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"please give at least one tag in request"`)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestRemoveVideoTag_Success(t *testing.T) {
+	video := &db.Video{ID: "video-id"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("RemoveVideoTag", video, "b-roll").Return(nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/tags/:tag", "delete", videoController.RemoveVideoTag)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id/tags/B-Roll", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"tag removed successfully"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestRemoveVideoTag_NotOnVideo(t *testing.T) {
+	video := &db.Video{ID: "video-id"}
+	mockRepo.On("GetVideoByID", "video-id").Return(video, nil)
+	mockRepo.On("RemoveVideoTag", video, "b-roll").Return(repository.ErrTagNotFound)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/tags/:tag", "delete", videoController.RemoveVideoTag)
+
+	req := httptest.NewRequest(http.MethodDelete, "/videos/video-id/tags/b-roll", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Contains(t, w.Body.String(), `"error":"tag not found on video"`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+// Search videos
+func TestSearchVideos_Success(t *testing.T) {
+	mockRepo.On("SearchVideos", "beach trip", 5, 10).Return([]repository.VideoSearchResult{
+		{
+			Video:          db.Video{ID: "video-id", Title: "Beach trip"},
+			Rank:           0.6,
+			TitleHighlight: "<mark>Beach</mark> <mark>trip</mark>",
+		},
+	}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/search", "get", videoController.SearchVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/search?q=+beach+trip+&limit=5&offset=10", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response struct {
+		Results []repository.VideoSearchResult `json:"results"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Results, 1)
+	assert.Equal(t, "video-id", response.Results[0].Video.ID)
+	assert.Equal(t, "<mark>Beach</mark> <mark>trip</mark>", response.Results[0].TitleHighlight)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestSearchVideos_NoResults(t *testing.T) {
+	mockRepo.On("SearchVideos", "nothing", DEFAULT_VIDEO_PAGE_SIZE, 0).Return(nil, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/search", "get", videoController.SearchVideos)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/search?q=nothing", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"results": []}`, w.Body.String())
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestSearchVideos_InvalidQuery(t *testing.T) {
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/search", "get", videoController.SearchVideos)
+
+	for _, target := range []string{"/videos/search", "/videos/search?q=+++", "/videos/search?q=a&limit=500", "/videos/search?q=a&offset=-1"} {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, target)
+	}
+	mockRepo.AssertNotCalled(t, "SearchVideos", mock.Anything, mock.Anything, mock.Anything)
+}
+
+// Video lineage
+func TestTrimVideo_RecordsLineage(t *testing.T) {
+	mockRepo.On("GetVideoByID", "video-id").Return(&db.Video{ID: "video-id", Name: "1-test.mp4", Path: "videos/1-test.mp4", Duration: 120.0}, nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		return len(video.Sources) == 1 &&
+			video.Sources[0].SourceVideoID == "video-id" &&
+			video.Sources[0].Operation == db.DERIVATION_TRIM &&
+			*video.Sources[0].StartTS == 10 && *video.Sources[0].EndTS == 50
+	})).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{FileSize: 2000000}, nil)
+
+	services.TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options services.TrimOptions) error {
+		return nil
+	}
+	services.ProbeVideo = mockProbeVideo
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/trim", "post", videoController.TrimVideo)
+
+	req := httptest.NewRequest(http.MethodPost, "/trim", bytes.NewBuffer([]byte(`{"video_id": "video-id", "start_ts": 10, "end_ts": 50}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestMergeVideos_RecordsLineageInRequestOrder(t *testing.T) {
+	mockRepo.On("GetVideosByIDs", []string{"video-id-2", "video-id-1"}).Return([]db.Video{
+		{ID: "video-id-1", Path: "videos/test-1.mp4", Duration: 100.0},
+		{ID: "video-id-2", Path: "videos/test-2.mp4", Duration: 120.0},
+	}, nil)
+	mockRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		return len(video.Sources) == 2 &&
+			video.Sources[0].SourceVideoID == "video-id-2" && video.Sources[0].Position == 0 &&
+			video.Sources[1].SourceVideoID == "video-id-1" && video.Sources[1].Position == 1 &&
+			video.Sources[0].Operation == db.DERIVATION_MERGE
+	})).Return(nil)
+	mockFS.On("Stat", mock.AnythingOfType("string")).Return(fsMock.MockFileInfo{}, nil)
+
+	var mergedPaths []string
+	services.MergeVideos = func(videoPaths []string, outputPath string, target services.MergeTarget) error {
+		mergedPaths = videoPaths
+		return nil
+	}
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/merge", "post", videoController.MergeVideos)
+
+	req := httptest.NewRequest(http.MethodPost, "/merge", bytes.NewBuffer([]byte(`{"video_ids": ["video-id-2", "video-id-1"]}`)))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []string{"videos/test-2.mp4", "videos/test-1.mp4"}, mergedPaths)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+		mockFS = new(fsMock.MockFileSystem)
+	})
+}
+
+func TestGetVideoLineage_Success(t *testing.T) {
+	mockRepo.On("GetVideoLineage", "video-id").Return(&repository.VideoLineage{
+		Ancestors: []db.VideoDerivation{
+			{VideoID: "video-id", SourceVideoID: "raw-id", Operation: db.DERIVATION_TRIM},
+		},
+		Descendants: []db.VideoDerivation{},
+		Videos:      []db.Video{{ID: "raw-id"}, {ID: "video-id"}},
+	}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/lineage", "get", videoController.GetVideoLineage)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/video-id/lineage", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"source_video_id":"raw-id"`)
+	assert.Contains(t, w.Body.String(), `"descendants":[]`)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
+
+func TestGetVideoLineage_NotFound(t *testing.T) {
+	mockRepo.On("GetVideoLineage", "missing-id").Return(&repository.VideoLineage{
+		Ancestors:   []db.VideoDerivation{},
+		Descendants: []db.VideoDerivation{},
+	}, nil)
+
+	videoController := NewVideoController(mockRepo, mockFS)
+	router := setupRouter("/videos/:id/lineage", "get", videoController.GetVideoLineage)
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/missing-id/lineage", nil)
+	w := httptest.NewRecorder()
+
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockRepo.AssertExpectations(t)
+
+	t.Cleanup(func() {
+		mockRepo = new(repoMock.MockVideoRepositoryImpl)
+	})
+}
@@ -18,5 +18,10 @@ func Init() {
 		log.Fatal("failed to connect to database", err)
 	}
 
-	DB.AutoMigrate(&Video{}, &SharedLink{})
+	DB.AutoMigrate(&Video{}, &SharedLink{}, &Tag{}, &VideoDerivation{}, &ImportJob{})
+	// derivation edges outlive the video they lead to, older schemas tied them to it
+	if DB.Migrator().HasConstraint(&VideoDerivation{}, "fk_videos_sources") {
+		DB.Migrator().DropConstraint(&VideoDerivation{}, "fk_videos_sources")
+	}
+	MigrateSearch(DB)
 }
@@ -8,21 +8,97 @@ import (
 )
 
 type Video struct {
-	ID          string       `gorm:"primary_key" json:"id"`
-	Name        string       `gorm:"not null" json:"name"`
-	Size        int64        `gorm:"not null" json:"size"`
-	Duration    float64      `gorm:"not null" json:"duration"`
-	Path        string       `gorm:"not null" json:"path"`
-	CreatedAt   time.Time    `gorm:"autoCreateTime" json:"created_at"`
-	SharedLinks []SharedLink `gorm:"foreign_key:VideoID" json:"shared_links"`
+	ID               string            `gorm:"primary_key" json:"id"`
+	Name             string            `gorm:"not null" json:"name"`
+	OriginalFilename string            `gorm:"not null;default:''" json:"original_filename"`
+	Size             int64             `gorm:"not null" json:"size"`
+	Duration         float64           `gorm:"not null" json:"duration"`
+	Path             string            `gorm:"not null" json:"-"`
+	Title            string            `gorm:"not null;default:''" json:"title"`
+	Description      string            `gorm:"not null;default:''" json:"description"`
+	Metadata         Metadata          `gorm:"type:jsonb;not null;default:'{}'" json:"metadata"`
+	SHA256           string            `gorm:"column:sha256;not null;default:'';index" json:"sha256"`
+	Media            VideoMedia        `gorm:"embedded" json:"media"`
+	CreatedAt        time.Time         `gorm:"autoCreateTime" json:"created_at"`
+	DeletedAt        gorm.DeletedAt    `gorm:"index" json:"deleted_at"`
+	SharedLinks      []SharedLink      `gorm:"foreign_key:VideoID" json:"shared_links"`
+	Tags             []Tag             `gorm:"many2many:video_tags" json:"tags"`
+	Sources          []VideoDerivation `gorm:"foreignKey:VideoID;constraint:-" json:"-"`
+}
+
+// VideoMedia is what ffprobe reports about the file of a video. Container is the
+// sniffed container, the codec and picture fields describe the first video stream
+// and the audio fields the first audio stream. BitRate is for the whole file, and
+// Rotation is the degrees clockwise the picture is turned on display.
+type VideoMedia struct {
+	Container   string  `gorm:"not null;default:''" json:"container"`
+	VideoCodec  string  `gorm:"not null;default:''" json:"video_codec"`
+	Width       int     `gorm:"not null;default:0" json:"width"`
+	Height      int     `gorm:"not null;default:0" json:"height"`
+	FrameRate   float64 `gorm:"not null;default:0" json:"frame_rate"`
+	BitRate     int64   `gorm:"not null;default:0" json:"bit_rate"`
+	PixelFormat string  `gorm:"not null;default:''" json:"pixel_format"`
+	Rotation    int     `gorm:"not null;default:0" json:"rotation"`
+	AudioCodec  string  `gorm:"not null;default:''" json:"audio_codec"`
+	SampleRate  int     `gorm:"not null;default:0" json:"sample_rate"`
+	Channels    int     `gorm:"not null;default:0" json:"channels"`
+	StreamCount int     `gorm:"not null;default:0" json:"stream_count"`
 }
 
 type SharedLink struct {
-	ID         string    `gorm:"primary_key" json:"id"`
-	VideoID    string    `gorm:"not null" json:"video_id"`
-	ExpiryDate time.Time `gorm:"not null" json:"expiry_date"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
-	Video      Video     `gorm:"foreign_key:VideoID;association_foreign_key:ID" json:"video"`
+	ID           string    `gorm:"primary_key" json:"id"`
+	VideoID      string    `gorm:"not null" json:"video_id"`
+	ExpiryDate   time.Time `gorm:"not null" json:"expiry_date"`
+	PasswordHash *string   `json:"-"`
+	MaxViews     *int      `json:"max_views"`
+	ViewCount    int       `gorm:"not null;default:0" json:"view_count"`
+	CreatedAt    time.Time `gorm:"autoCreateTime" json:"created_at"`
+	Video        Video     `gorm:"foreign_key:VideoID;association_foreign_key:ID" json:"-"`
+}
+
+type Tag struct {
+	ID        string    `gorm:"primary_key" json:"id"`
+	Name      string    `gorm:"not null;uniqueIndex" json:"name"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+const (
+	DERIVATION_TRIM  = "trim"
+	DERIVATION_MERGE = "merge"
+)
+
+// VideoDerivation records that VideoID was produced from SourceVideoID. A merge
+// stores one edge per input with Position giving the input order; a trim stores a
+// single edge with the cut points. Edges outlive both of their videos so a derived
+// video can still be traced to its raw uploads after anything in between is deleted.
+type VideoDerivation struct {
+	ID            string    `gorm:"primary_key" json:"id"`
+	VideoID       string    `gorm:"not null;index" json:"video_id"`
+	SourceVideoID string    `gorm:"not null;index" json:"source_video_id"`
+	Operation     string    `gorm:"not null" json:"operation"`
+	Position      int       `gorm:"not null;default:0" json:"position"`
+	StartTS       *float64  `json:"start_ts,omitempty"`
+	EndTS         *float64  `json:"end_ts,omitempty"`
+	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+const (
+	IMPORT_PENDING   = "pending"
+	IMPORT_RUNNING   = "running"
+	IMPORT_SUCCEEDED = "succeeded"
+	IMPORT_FAILED    = "failed"
+)
+
+// ImportJob tracks the download of a video from a remote url. VideoID is set once the
+// import succeeded, Error once it failed.
+type ImportJob struct {
+	ID        string    `gorm:"primary_key" json:"id"`
+	URL       string    `gorm:"not null" json:"url"`
+	Status    string    `gorm:"not null;index" json:"status"`
+	Error     string    `gorm:"not null;default:''" json:"error,omitempty"`
+	VideoID   *string   `json:"video_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
 func (v *Video) BeforeCreate(tx *gorm.DB) (err error) {
@@ -35,6 +111,24 @@ func (s *SharedLink) BeforeCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+func (t *Tag) BeforeCreate(tx *gorm.DB) (err error) {
+	// existing tags pass through here when appended to a video, keep their id
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return
+}
+
+func (d *VideoDerivation) BeforeCreate(tx *gorm.DB) (err error) {
+	d.ID = uuid.New().String()
+	return
+}
+
+func (j *ImportJob) BeforeCreate(tx *gorm.DB) (err error) {
+	j.ID = uuid.New().String()
+	return
+}
+
 func (Video) TableName() string {
 	return "videos"
 }
@@ -42,3 +136,15 @@ func (Video) TableName() string {
 func (SharedLink) TableName() string {
 	return "shared_links"
 }
+
+func (Tag) TableName() string {
+	return "tags"
+}
+
+func (VideoDerivation) TableName() string {
+	return "video_derivations"
+}
+
+func (ImportJob) TableName() string {
+	return "import_jobs"
+}
@@ -0,0 +1,37 @@
+package db
+
+import (
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// SEARCH_CONFIG is the text search configuration used for both the indexes and the
+// queries. "simple" avoids stemming so names, tags and mixed-language titles match
+// as typed.
+const SEARCH_CONFIG = "simple"
+
+// VIDEO_SEARCH_DOCUMENT and TAG_SEARCH_DOCUMENT must be used verbatim in queries,
+// otherwise postgres will not pick the expression indexes created below.
+const (
+	VIDEO_SEARCH_DOCUMENT = "to_tsvector('" + SEARCH_CONFIG + "', coalesce(title, '') || ' ' || coalesce(description, ''))"
+	TAG_SEARCH_DOCUMENT   = "to_tsvector('" + SEARCH_CONFIG + "', name)"
+)
+
+// MigrateSearch creates the full-text search indexes. Only postgres supports them;
+// other dialects fall back to LIKE queries in the repository.
+func MigrateSearch(db *gorm.DB) {
+	if db.Dialector.Name() != "postgres" {
+		return
+	}
+
+	statements := []string{
+		"CREATE INDEX IF NOT EXISTS idx_videos_search ON videos USING GIN (" + VIDEO_SEARCH_DOCUMENT + ")",
+		"CREATE INDEX IF NOT EXISTS idx_tags_search ON tags USING GIN (" + TAG_SEARCH_DOCUMENT + ")",
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			log.Println("[db] failed to create search index: ", err.Error())
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"errors"
+	"log"
+
+	"github.com/3ssalunke/videoverse/db"
+	"gorm.io/gorm"
+)
+
+type ImportJobRepository interface {
+	CreateImportJob(job *db.ImportJob) error
+	GetImportJobByID(id string) (*db.ImportJob, error)
+	UpdateImportJob(job *db.ImportJob) error
+	GetUnfinishedImportJobs() ([]db.ImportJob, error)
+}
+
+type ImportJobRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewImportJobRepository(db *gorm.DB) ImportJobRepository {
+	return &ImportJobRepositoryImpl{db}
+}
+
+func (r *ImportJobRepositoryImpl) CreateImportJob(job *db.ImportJob) error {
+	return r.db.Create(job).Error
+}
+
+func (r *ImportJobRepositoryImpl) GetImportJobByID(id string) (*db.ImportJob, error) {
+	var job db.ImportJob
+	result := r.db.Where("id = ?", id).First(&job)
+	if result.Error != nil {
+		log.Println("[repo] error while getting import job by id: ", result.Error.Error())
+		return nil, errors.New("error getting import job by id")
+	}
+
+	return &job, nil
+}
+
+func (r *ImportJobRepositoryImpl) UpdateImportJob(job *db.ImportJob) error {
+	result := r.db.Model(job).Select("status", "error", "video_id").Updates(job)
+	if result.Error != nil {
+		log.Println("[repo] error while updating import job: ", result.Error.Error())
+		return errors.New("error updating import job")
+	}
+
+	return nil
+}
+
+// GetUnfinishedImportJobs returns the jobs that were pending or running, oldest first,
+// so they can be picked up again after a restart.
+func (r *ImportJobRepositoryImpl) GetUnfinishedImportJobs() ([]db.ImportJob, error) {
+	var jobs []db.ImportJob
+	result := r.db.Where("status IN ?", []string{db.IMPORT_PENDING, db.IMPORT_RUNNING}).Order("created_at ASC").Find(&jobs)
+	if result.Error != nil {
+		log.Println("[repo] error while getting unfinished import jobs: ", result.Error.Error())
+		return nil, errors.New("error getting unfinished import jobs")
+	}
+
+	return jobs, nil
+}
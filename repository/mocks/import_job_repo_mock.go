@@ -0,0 +1,40 @@
+package mocks
+
+import (
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockImportJobRepositoryImpl struct {
+	mock.Mock
+}
+
+func (m *MockImportJobRepositoryImpl) CreateImportJob(job *db.ImportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepositoryImpl) GetImportJobByID(id string) (*db.ImportJob, error) {
+	args := m.Called(id)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*db.ImportJob), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockImportJobRepositoryImpl) UpdateImportJob(job *db.ImportJob) error {
+	args := m.Called(job)
+	return args.Error(0)
+}
+
+func (m *MockImportJobRepositoryImpl) GetUnfinishedImportJobs() ([]db.ImportJob, error) {
+	args := m.Called()
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]db.ImportJob), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
@@ -0,0 +1,57 @@
+package mocks
+
+import (
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockSharedLinkRepositoryImpl struct {
+	mock.Mock
+}
+
+func (m *MockSharedLinkRepositoryImpl) CreateSharedLink(link *db.SharedLink) error {
+	args := m.Called(link)
+	return args.Error(0)
+}
+
+func (m *MockSharedLinkRepositoryImpl) GetSharedLinkByID(id string) (*db.SharedLink, error) {
+	args := m.Called(id)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*db.SharedLink), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockSharedLinkRepositoryImpl) GetSharedLinksByVideoID(videoID string) ([]db.SharedLink, error) {
+	args := m.Called(videoID)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]db.SharedLink), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockSharedLinkRepositoryImpl) UpdateSharedLinkExpiry(id string, expiryDate time.Time) error {
+	args := m.Called(id, expiryDate)
+	return args.Error(0)
+}
+
+func (m *MockSharedLinkRepositoryImpl) DeleteSharedLink(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSharedLinkRepositoryImpl) IncrementSharedLinkViews(id string) (bool, error) {
+	args := m.Called(id)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockSharedLinkRepositoryImpl) DeleteExpiredSharedLinks(before time.Time, limit int) (int64, error) {
+	args := m.Called(before, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
@@ -1,7 +1,10 @@
 package mocks
 
 import (
+	"time"
+
 	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
 	"github.com/stretchr/testify/mock"
 )
 
@@ -33,3 +36,103 @@ func (m *MockVideoRepositoryImpl) GetVideosByIDs(ids []string) ([]db.Video, erro
 
 	return nil, args.Error(1)
 }
+
+func (m *MockVideoRepositoryImpl) GetVideoDetailsByID(id string) (*db.Video, error) {
+	args := m.Called(id)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*db.Video), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockVideoRepositoryImpl) UpdateVideoDetails(video *db.Video) error {
+	args := m.Called(video)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepositoryImpl) ListVideos(params repository.VideoListParams) ([]db.Video, string, error) {
+	args := m.Called(params)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]db.Video), args.String(1), args.Error(2)
+	}
+
+	return nil, args.String(1), args.Error(2)
+}
+
+func (m *MockVideoRepositoryImpl) DeleteVideo(video *db.Video, removeFile func() error) error {
+	args := m.Called(video)
+	if err := args.Error(0); err != nil {
+		return err
+	}
+
+	return removeFile()
+}
+
+func (m *MockVideoRepositoryImpl) TrashVideo(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepositoryImpl) RestoreVideo(id string) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepositoryImpl) GetTrashedVideoByID(id string) (*db.Video, error) {
+	args := m.Called(id)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*db.Video), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockVideoRepositoryImpl) GetTrashedVideos(deletedBefore time.Time, limit int) ([]db.Video, error) {
+	args := m.Called(deletedBefore, limit)
+
+	if args.Get(0) != nil {
+		return args.Get(0).([]db.Video), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockVideoRepositoryImpl) AddVideoTags(video *db.Video, names []string) error {
+	args := m.Called(video, names)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepositoryImpl) RemoveVideoTag(video *db.Video, name string) error {
+	args := m.Called(video, name)
+	return args.Error(0)
+}
+
+func (m *MockVideoRepositoryImpl) SearchVideos(query string, limit, offset int) ([]repository.VideoSearchResult, error) {
+	args := m.Called(query, limit, offset)
+	if results, ok := args.Get(0).([]repository.VideoSearchResult); ok {
+		return results, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockVideoRepositoryImpl) GetVideoLineage(id string) (*repository.VideoLineage, error) {
+	args := m.Called(id)
+	if lineage, ok := args.Get(0).(*repository.VideoLineage); ok {
+		return lineage, args.Error(1)
+	}
+	return nil, args.Error(1)
+}
+
+func (m *MockVideoRepositoryImpl) GetVideoBySHA256(sha256 string) (*db.Video, error) {
+	args := m.Called(sha256)
+
+	if args.Get(0) != nil {
+		return args.Get(0).(*db.Video), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
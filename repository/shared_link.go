@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	"gorm.io/gorm"
+)
+
+type SharedLinkRepository interface {
+	CreateSharedLink(link *db.SharedLink) error
+	GetSharedLinkByID(id string) (*db.SharedLink, error)
+	GetSharedLinksByVideoID(videoID string) ([]db.SharedLink, error)
+	UpdateSharedLinkExpiry(id string, expiryDate time.Time) error
+	DeleteSharedLink(id string) error
+	IncrementSharedLinkViews(id string) (bool, error)
+	DeleteExpiredSharedLinks(before time.Time, limit int) (int64, error)
+}
+
+type SharedLinkRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewSharedLinkRepository(db *gorm.DB) SharedLinkRepository {
+	return &SharedLinkRepositoryImpl{db}
+}
+
+func (r *SharedLinkRepositoryImpl) CreateSharedLink(link *db.SharedLink) error {
+	return r.db.Create(link).Error
+}
+
+func (r *SharedLinkRepositoryImpl) GetSharedLinkByID(id string) (*db.SharedLink, error) {
+	var link db.SharedLink
+	result := r.db.Where("id = ?", id).First(&link)
+	if result.Error != nil {
+		log.Println("[repo] error while getting shared link by id: ", result.Error.Error())
+		return nil, errors.New("error getting shared link by id")
+	}
+
+	return &link, nil
+}
+
+func (r *SharedLinkRepositoryImpl) GetSharedLinksByVideoID(videoID string) ([]db.SharedLink, error) {
+	var links []db.SharedLink
+	err := r.db.Model(&db.Video{ID: videoID}).Order("created_at desc").Association("SharedLinks").Find(&links)
+	if err != nil {
+		log.Println("[repo] error while getting shared links by video id: ", err.Error())
+		return nil, errors.New("error getting shared links by video id")
+	}
+
+	return links, nil
+}
+
+func (r *SharedLinkRepositoryImpl) UpdateSharedLinkExpiry(id string, expiryDate time.Time) error {
+	result := r.db.Model(&db.SharedLink{}).Where("id = ?", id).Update("expiry_date", expiryDate)
+	if result.Error != nil {
+		log.Println("[repo] error while updating shared link expiry: ", result.Error.Error())
+		return errors.New("error updating shared link expiry")
+	}
+
+	return nil
+}
+
+func (r *SharedLinkRepositoryImpl) DeleteSharedLink(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&db.SharedLink{})
+	if result.Error != nil {
+		log.Println("[repo] error while deleting shared link: ", result.Error.Error())
+		return errors.New("error deleting shared link")
+	}
+
+	return nil
+}
+
+// IncrementSharedLinkViews records one view on the link. It reports false, without
+// counting, when the link has already used up its max views.
+func (r *SharedLinkRepositoryImpl) IncrementSharedLinkViews(id string) (bool, error) {
+	result := r.db.Model(&db.SharedLink{}).
+		Where("id = ? AND (max_views IS NULL OR view_count < max_views)", id).
+		Update("view_count", gorm.Expr("view_count + 1"))
+	if result.Error != nil {
+		log.Println("[repo] error while incrementing shared link views: ", result.Error.Error())
+		return false, errors.New("error incrementing shared link views")
+	}
+
+	return result.RowsAffected == 1, nil
+}
+
+// DeleteExpiredSharedLinks removes at most limit links that expired before the given
+// time and returns how many rows were deleted.
+func (r *SharedLinkRepositoryImpl) DeleteExpiredSharedLinks(before time.Time, limit int) (int64, error) {
+	expired := r.db.Model(&db.SharedLink{}).Select("id").Where("expiry_date < ?", before).Limit(limit)
+	result := r.db.Where("id IN (?)", expired).Delete(&db.SharedLink{})
+	if result.Error != nil {
+		log.Println("[repo] error while deleting expired shared links: ", result.Error.Error())
+		return 0, errors.New("error deleting expired shared links")
+	}
+
+	return result.RowsAffected, nil
+}
@@ -1,17 +1,100 @@
 package repository
 
 import (
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/3ssalunke/videoverse/db"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type VideoRepository interface {
 	CreateVideo(video *db.Video) error
 	GetVideoByID(id string) (*db.Video, error)
 	GetVideosByIDs(ids []string) ([]db.Video, error)
+	GetVideoBySHA256(sha256 string) (*db.Video, error)
+	GetVideoDetailsByID(id string) (*db.Video, error)
+	UpdateVideoDetails(video *db.Video) error
+	ListVideos(params VideoListParams) ([]db.Video, string, error)
+	DeleteVideo(video *db.Video, removeFile func() error) error
+	TrashVideo(id string) error
+	RestoreVideo(id string) error
+	GetTrashedVideoByID(id string) (*db.Video, error)
+	GetTrashedVideos(deletedBefore time.Time, limit int) ([]db.Video, error)
+	AddVideoTags(video *db.Video, names []string) error
+	RemoveVideoTag(video *db.Video, name string) error
+	SearchVideos(query string, limit, offset int) ([]VideoSearchResult, error)
+	GetVideoLineage(id string) (*VideoLineage, error)
+}
+
+var (
+	ErrInvalidCursor = errors.New("invalid cursor")
+	ErrTagNotFound   = errors.New("tag not found on video")
+)
+
+var videoSortColumns = map[string]bool{
+	"created_at": true,
+	"duration":   true,
+	"size":       true,
+}
+
+type VideoListParams struct {
+	MinDuration            *float64
+	MaxDuration            *float64
+	MinSize                *int64
+	MaxSize                *int64
+	CreatedAfter           *time.Time
+	CreatedBefore          *time.Time
+	OriginalFilenamePrefix string
+	TagsAny                []string
+	TagsAll                []string
+	SortBy                 string
+	SortDesc               bool
+	Limit                  int
+	Cursor                 string
+}
+
+// VideoSearchResult is a video matching a search query together with its rank and
+// the title and description with the matched terms wrapped in <mark> tags.
+type VideoSearchResult struct {
+	Video                db.Video `json:"video"`
+	Rank                 float64  `json:"rank"`
+	TitleHighlight       string   `json:"title_highlight"`
+	DescriptionHighlight string   `json:"description_highlight"`
+}
+
+// VideoLineage holds every derivation edge leading to a video and every edge leading
+// away from it, along with the videos those edges mention that still exist.
+type VideoLineage struct {
+	Ancestors   []db.VideoDerivation `json:"ancestors"`
+	Descendants []db.VideoDerivation `json:"descendants"`
+	Videos      []db.Video           `json:"videos"`
+}
+
+type videoSearchHit struct {
+	ID                   string
+	Rank                 float64
+	TitleHighlight       string
+	DescriptionHighlight string
+}
+
+const (
+	SEARCH_HIGHLIGHT_START = "<mark>"
+	SEARCH_HIGHLIGHT_STOP  = "</mark>"
+)
+
+// videoCursor points at the last video of a page by its sort value and id, so
+// the next page can continue from there regardless of inserts in between.
+type videoCursor struct {
+	Value string `json:"v"`
+	ID    string `json:"id"`
 }
 
 type VideoRepositoryImpl struct {
@@ -49,3 +132,482 @@ func (r *VideoRepositoryImpl) GetVideosByIDs(ids []string) ([]db.Video, error) {
 
 	return videos, nil
 }
+
+// GetVideoBySHA256 returns the oldest video with the given content hash, or nil when
+// there is none. Trashed videos are not considered.
+func (r *VideoRepositoryImpl) GetVideoBySHA256(sha256 string) (*db.Video, error) {
+	var videos []db.Video
+	result := r.db.Where("sha256 = ?", sha256).Order("created_at ASC").Limit(1).Find(&videos)
+	if result.Error != nil {
+		log.Println("[repo] error while getting video by sha256: ", result.Error.Error())
+		return nil, errors.New("error getting video by sha256")
+	}
+
+	if len(videos) == 0 {
+		return nil, nil
+	}
+	return &videos[0], nil
+}
+
+func (r *VideoRepositoryImpl) GetVideoDetailsByID(id string) (*db.Video, error) {
+	var video db.Video
+	result := r.db.Preload("SharedLinks", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("created_at desc")
+	}).Preload("Tags").Where("id = ?", id).First(&video)
+	if result.Error != nil {
+		log.Println("[repo] error while getting video details by id: ", result.Error.Error())
+		return nil, errors.New("error getting video details by id")
+	}
+
+	return &video, nil
+}
+
+func (r *VideoRepositoryImpl) UpdateVideoDetails(video *db.Video) error {
+	result := r.db.Model(video).Select("title", "description", "metadata").Updates(video)
+	if result.Error != nil {
+		log.Println("[repo] error while updating video details: ", result.Error.Error())
+		return errors.New("error updating video details")
+	}
+
+	return nil
+}
+
+func (r *VideoRepositoryImpl) ListVideos(params VideoListParams) ([]db.Video, string, error) {
+	if !videoSortColumns[params.SortBy] {
+		return nil, "", fmt.Errorf("unsupported sort column %s", params.SortBy)
+	}
+
+	query := r.db.Model(&db.Video{})
+	if params.MinDuration != nil {
+		query = query.Where("duration >= ?", *params.MinDuration)
+	}
+	if params.MaxDuration != nil {
+		query = query.Where("duration <= ?", *params.MaxDuration)
+	}
+	if params.MinSize != nil {
+		query = query.Where("size >= ?", *params.MinSize)
+	}
+	if params.MaxSize != nil {
+		query = query.Where("size <= ?", *params.MaxSize)
+	}
+	if params.CreatedAfter != nil {
+		query = query.Where("created_at >= ?", *params.CreatedAfter)
+	}
+	if params.CreatedBefore != nil {
+		query = query.Where("created_at < ?", *params.CreatedBefore)
+	}
+	if params.OriginalFilenamePrefix != "" {
+		query = query.Where(`original_filename LIKE ? ESCAPE '\'`, escapeLike(params.OriginalFilenamePrefix)+"%")
+	}
+	if len(params.TagsAny) > 0 {
+		query = query.Where("id IN (?)", r.db.Table("video_tags").
+			Select("video_tags.video_id").
+			Joins("JOIN tags ON tags.id = video_tags.tag_id").
+			Where("tags.name IN ?", params.TagsAny))
+	}
+	if len(params.TagsAll) > 0 {
+		query = query.Where("id IN (?)", r.db.Table("video_tags").
+			Select("video_tags.video_id").
+			Joins("JOIN tags ON tags.id = video_tags.tag_id").
+			Where("tags.name IN ?", params.TagsAll).
+			Group("video_tags.video_id").
+			Having("COUNT(DISTINCT tags.name) = ?", len(params.TagsAll)))
+	}
+
+	direction, comparator := "ASC", ">"
+	if params.SortDesc {
+		direction, comparator = "DESC", "<"
+	}
+
+	if params.Cursor != "" {
+		cursorValue, cursorID, err := decodeVideoCursor(params.Cursor, params.SortBy)
+		if err != nil {
+			return nil, "", err
+		}
+		query = query.Where(
+			fmt.Sprintf("(%[1]s %[2]s ?) OR (%[1]s = ? AND id %[2]s ?)", params.SortBy, comparator),
+			cursorValue, cursorValue, cursorID,
+		)
+	}
+
+	var videos []db.Video
+	result := query.
+		Preload("Tags").
+		Order(fmt.Sprintf("%s %s, id %s", params.SortBy, direction, direction)).
+		Limit(params.Limit + 1).
+		Find(&videos)
+	if result.Error != nil {
+		log.Println("[repo] error while listing videos: ", result.Error.Error())
+		return nil, "", errors.New("error listing videos")
+	}
+
+	nextCursor := ""
+	if len(videos) > params.Limit {
+		videos = videos[:params.Limit]
+		nextCursor = encodeVideoCursor(videos[len(videos)-1], params.SortBy)
+	}
+
+	return videos, nextCursor, nil
+}
+
+// DeleteVideo permanently removes the video, trashed or not, its shared links and
+// its tags in one transaction. Derivation edges to and from it are kept so lineage
+// still reaches past it. removeFile runs last inside the transaction, so a failure
+// to delete the file keeps the rows.
+func (r *VideoRepositoryImpl) DeleteVideo(video *db.Video, removeFile func() error) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("video_id = ?", video.ID).Delete(&db.SharedLink{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(video).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("id = ?", video.ID).Delete(&db.Video{}).Error; err != nil {
+			return err
+		}
+		return removeFile()
+	})
+	if err != nil {
+		log.Println("[repo] error while deleting video: ", err.Error())
+		return errors.New("error deleting video")
+	}
+
+	return nil
+}
+
+func (r *VideoRepositoryImpl) TrashVideo(id string) error {
+	result := r.db.Where("id = ?", id).Delete(&db.Video{})
+	if result.Error != nil {
+		log.Println("[repo] error while trashing video: ", result.Error.Error())
+		return errors.New("error trashing video")
+	}
+
+	return nil
+}
+
+func (r *VideoRepositoryImpl) RestoreVideo(id string) error {
+	result := r.db.Unscoped().Model(&db.Video{}).Where("id = ?", id).Update("deleted_at", nil)
+	if result.Error != nil {
+		log.Println("[repo] error while restoring video: ", result.Error.Error())
+		return errors.New("error restoring video")
+	}
+
+	return nil
+}
+
+func (r *VideoRepositoryImpl) GetTrashedVideoByID(id string) (*db.Video, error) {
+	var video db.Video
+	result := r.db.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", id).First(&video)
+	if result.Error != nil {
+		log.Println("[repo] error while getting trashed video by id: ", result.Error.Error())
+		return nil, errors.New("error getting trashed video by id")
+	}
+
+	return &video, nil
+}
+
+// GetTrashedVideos returns up to limit videos that were trashed before the given
+// time, oldest first.
+func (r *VideoRepositoryImpl) GetTrashedVideos(deletedBefore time.Time, limit int) ([]db.Video, error) {
+	var videos []db.Video
+	result := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", deletedBefore).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&videos)
+	if result.Error != nil {
+		log.Println("[repo] error while getting trashed videos: ", result.Error.Error())
+		return nil, errors.New("error getting trashed videos")
+	}
+
+	return videos, nil
+}
+
+// AddVideoTags attaches the named tags to the video, creating tags that do not
+// exist yet. Tags the video already has are left as they are.
+func (r *VideoRepositoryImpl) AddVideoTags(video *db.Video, names []string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		tags := make([]db.Tag, 0, len(names))
+		for _, name := range names {
+			var tag db.Tag
+			if err := tx.Where(db.Tag{Name: name}).FirstOrCreate(&tag).Error; err != nil {
+				return err
+			}
+			tags = append(tags, tag)
+		}
+
+		return tx.Model(video).Association("Tags").Append(tags)
+	})
+	if err != nil {
+		log.Println("[repo] error while adding video tags: ", err.Error())
+		return errors.New("error adding video tags")
+	}
+
+	return nil
+}
+
+// RemoveVideoTag untags the video. It returns ErrTagNotFound when the video does not
+// carry the tag, whether or not the tag exists at all.
+func (r *VideoRepositoryImpl) RemoveVideoTag(video *db.Video, name string) error {
+	result := r.db.Exec(
+		"DELETE FROM video_tags WHERE video_id = ? AND tag_id IN (SELECT id FROM tags WHERE name = ?)",
+		video.ID, name)
+	if result.Error != nil {
+		log.Println("[repo] error while removing video tag: ", result.Error.Error())
+		return errors.New("error removing video tag")
+	}
+	if result.RowsAffected == 0 {
+		return ErrTagNotFound
+	}
+
+	return nil
+}
+
+// SearchVideos returns the videos whose title, description or tags match the query,
+// best match first. Postgres uses the full-text indexes from db.MigrateSearch; other
+// dialects, such as the sqlite database used in tests, fall back to substring
+// matching.
+func (r *VideoRepositoryImpl) SearchVideos(query string, limit, offset int) ([]VideoSearchResult, error) {
+	var hits []videoSearchHit
+	var err error
+	if r.db.Dialector.Name() == "postgres" {
+		hits, err = r.searchVideosFullText(query, limit, offset)
+	} else {
+		hits, err = r.searchVideosLike(query, limit, offset)
+	}
+	if err != nil {
+		log.Println("[repo] error while searching videos: ", err.Error())
+		return nil, errors.New("error searching videos")
+	}
+
+	if len(hits) == 0 {
+		return []VideoSearchResult{}, nil
+	}
+
+	ids := make([]string, len(hits))
+	for i, hit := range hits {
+		ids[i] = hit.ID
+	}
+
+	var videos []db.Video
+	if err := r.db.Preload("Tags").Where("id IN ?", ids).Find(&videos).Error; err != nil {
+		log.Println("[repo] error while loading searched videos: ", err.Error())
+		return nil, errors.New("error searching videos")
+	}
+
+	videosByID := make(map[string]db.Video, len(videos))
+	for _, video := range videos {
+		videosByID[video.ID] = video
+	}
+
+	results := make([]VideoSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		video, ok := videosByID[hit.ID]
+		if !ok {
+			continue
+		}
+		results = append(results, VideoSearchResult{
+			Video:                video,
+			Rank:                 hit.Rank,
+			TitleHighlight:       hit.TitleHighlight,
+			DescriptionHighlight: hit.DescriptionHighlight,
+		})
+	}
+
+	return results, nil
+}
+
+func (r *VideoRepositoryImpl) searchVideosFullText(query string, limit, offset int) ([]videoSearchHit, error) {
+	tagDocument := fmt.Sprintf(
+		"to_tsvector('%s', coalesce((SELECT string_agg(tags.name, ' ') FROM video_tags JOIN tags ON tags.id = video_tags.tag_id WHERE video_tags.video_id = videos.id), ''))",
+		db.SEARCH_CONFIG,
+	)
+	headlineOptions := fmt.Sprintf("StartSel=%s, StopSel=%s", SEARCH_HIGHLIGHT_START, SEARCH_HIGHLIGHT_STOP)
+
+	var hits []videoSearchHit
+	result := r.db.Raw(fmt.Sprintf(`
+		SELECT videos.id,
+			ts_rank(%[1]s || %[2]s, search_query) AS rank,
+			ts_headline('%[3]s', coalesce(videos.title, ''), search_query, '%[4]s, HighlightAll=true') AS title_highlight,
+			ts_headline('%[3]s', coalesce(videos.description, ''), search_query, '%[4]s, MaxFragments=2') AS description_highlight
+		FROM videos, websearch_to_tsquery('%[3]s', ?) AS search_query
+		WHERE videos.deleted_at IS NULL
+			AND (%[1]s @@ search_query OR videos.id IN (
+				SELECT video_tags.video_id FROM video_tags
+				JOIN tags ON tags.id = video_tags.tag_id
+				WHERE %[5]s @@ search_query))
+		ORDER BY rank DESC, videos.created_at DESC, videos.id
+		LIMIT ? OFFSET ?`,
+		db.VIDEO_SEARCH_DOCUMENT, tagDocument, db.SEARCH_CONFIG, headlineOptions, db.TAG_SEARCH_DOCUMENT,
+	), query, limit, offset).Scan(&hits)
+
+	return hits, result.Error
+}
+
+// searchVideosLike matches the query as a case-insensitive substring. Title matches
+// rank above description and tag matches.
+func (r *VideoRepositoryImpl) searchVideosLike(query string, limit, offset int) ([]videoSearchHit, error) {
+	pattern := "%" + escapeLike(strings.ToLower(query)) + "%"
+
+	var videos []db.Video
+	result := r.db.
+		Where(`LOWER(title) LIKE ? ESCAPE '\' OR LOWER(description) LIKE ? ESCAPE '\' OR id IN (?)`,
+			pattern, pattern, r.db.Table("video_tags").
+				Select("video_tags.video_id").
+				Joins("JOIN tags ON tags.id = video_tags.tag_id").
+				Where(`tags.name LIKE ? ESCAPE '\'`, pattern)).
+		Order(clause.OrderBy{Expression: clause.Expr{
+			SQL:  `CASE WHEN LOWER(title) LIKE ? ESCAPE '\' THEN 0 ELSE 1 END, created_at DESC, id`,
+			Vars: []any{pattern},
+		}}).
+		Limit(limit).
+		Offset(offset).
+		Find(&videos)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	hits := make([]videoSearchHit, len(videos))
+	for i, video := range videos {
+		hits[i] = videoSearchHit{
+			ID:                   video.ID,
+			Rank:                 0.5,
+			TitleHighlight:       highlight(video.Title, query),
+			DescriptionHighlight: highlight(video.Description, query),
+		}
+		if strings.Contains(strings.ToLower(video.Title), strings.ToLower(query)) {
+			hits[i].Rank = 1
+		}
+	}
+
+	return hits, nil
+}
+
+// highlight wraps every case-insensitive occurrence of term in text with the
+// highlight markers. Offsets found in the lowercased text are only valid in the
+// original when lowercasing kept the byte lengths, otherwise nothing is marked.
+func highlight(text, term string) string {
+	if term == "" {
+		return text
+	}
+
+	lowerText, lowerTerm := strings.ToLower(text), strings.ToLower(term)
+	if len(lowerText) != len(text) || len(lowerTerm) != len(term) {
+		return text
+	}
+
+	var builder strings.Builder
+	for {
+		index := strings.Index(lowerText, lowerTerm)
+		if index < 0 {
+			builder.WriteString(text)
+			return builder.String()
+		}
+		end := index + len(lowerTerm)
+		builder.WriteString(text[:index])
+		builder.WriteString(SEARCH_HIGHLIGHT_START)
+		builder.WriteString(text[index:end])
+		builder.WriteString(SEARCH_HIGHLIGHT_STOP)
+		text, lowerText = text[end:], lowerText[end:]
+	}
+}
+
+// GetVideoLineage walks the derivation edges up to the raw uploads the video was
+// made from and down to everything made from it. Trashed videos are included.
+func (r *VideoRepositoryImpl) GetVideoLineage(id string) (*VideoLineage, error) {
+	lineage := &VideoLineage{}
+
+	result := r.db.Raw(`
+		WITH RECURSIVE ancestors AS (
+			SELECT * FROM video_derivations WHERE video_id = ?
+			UNION
+			SELECT video_derivations.* FROM video_derivations
+			JOIN ancestors ON video_derivations.video_id = ancestors.source_video_id
+		)
+		SELECT * FROM ancestors ORDER BY created_at, video_id, position`, id).Scan(&lineage.Ancestors)
+	if result.Error != nil {
+		log.Println("[repo] error while getting video ancestors: ", result.Error.Error())
+		return nil, errors.New("error getting video lineage")
+	}
+
+	result = r.db.Raw(`
+		WITH RECURSIVE descendants AS (
+			SELECT * FROM video_derivations WHERE source_video_id = ?
+			UNION
+			SELECT video_derivations.* FROM video_derivations
+			JOIN descendants ON video_derivations.source_video_id = descendants.video_id
+		)
+		SELECT * FROM descendants ORDER BY created_at, video_id, position`, id).Scan(&lineage.Descendants)
+	if result.Error != nil {
+		log.Println("[repo] error while getting video descendants: ", result.Error.Error())
+		return nil, errors.New("error getting video lineage")
+	}
+
+	ids := []string{id}
+	for _, edges := range [][]db.VideoDerivation{lineage.Ancestors, lineage.Descendants} {
+		for _, edge := range edges {
+			ids = append(ids, edge.VideoID, edge.SourceVideoID)
+		}
+	}
+
+	result = r.db.Unscoped().Where("id IN ?", ids).Order("created_at").Find(&lineage.Videos)
+	if result.Error != nil {
+		log.Println("[repo] error while getting lineage videos: ", result.Error.Error())
+		return nil, errors.New("error getting video lineage")
+	}
+
+	if lineage.Ancestors == nil {
+		lineage.Ancestors = []db.VideoDerivation{}
+	}
+	if lineage.Descendants == nil {
+		lineage.Descendants = []db.VideoDerivation{}
+	}
+
+	return lineage, nil
+}
+
+func encodeVideoCursor(video db.Video, sortBy string) string {
+	cursor := videoCursor{ID: video.ID}
+	switch sortBy {
+	case "duration":
+		cursor.Value = strconv.FormatFloat(video.Duration, 'g', -1, 64)
+	case "size":
+		cursor.Value = strconv.FormatInt(video.Size, 10)
+	default:
+		cursor.Value = video.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeVideoCursor(encoded, sortBy string) (any, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var cursor videoCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == "" {
+		return nil, "", ErrInvalidCursor
+	}
+
+	var value any
+	switch sortBy {
+	case "duration":
+		value, err = strconv.ParseFloat(cursor.Value, 64)
+	case "size":
+		value, err = strconv.ParseInt(cursor.Value, 10, 64)
+	default:
+		value, err = time.Parse(time.RFC3339Nano, cursor.Value)
+	}
+	if err != nil {
+		return nil, "", ErrInvalidCursor
+	}
+
+	return value, cursor.ID, nil
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+}
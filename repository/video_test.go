@@ -0,0 +1,211 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	testDB, err := gorm.Open(sqlite.Open(":memory:?_foreign_keys=on"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("failed to open test database: %v", err)
+	}
+	if err := testDB.AutoMigrate(&db.Video{}, &db.SharedLink{}, &db.Tag{}, &db.VideoDerivation{}); err != nil {
+		t.Fatalf("failed to migrate test database: %v", err)
+	}
+	return testDB
+}
+
+func TestSearchVideos_LikeFallback(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	beach := &db.Video{Name: "a.mp4", Title: "Beach day", Description: "Waves", Metadata: db.Metadata{}}
+	sunset := &db.Video{Name: "b.mp4", Title: "Sunset", Description: "A walk on the beach", Metadata: db.Metadata{}}
+	tagged := &db.Video{Name: "c.mp4", Title: "Untitled", Metadata: db.Metadata{}}
+	trashed := &db.Video{Name: "d.mp4", Title: "Beach party", Metadata: db.Metadata{}}
+	other := &db.Video{Name: "e.mp4", Title: "Mountains", Metadata: db.Metadata{}}
+	for _, video := range []*db.Video{beach, sunset, tagged, trashed, other} {
+		assert.NoError(t, repo.CreateVideo(video))
+	}
+	assert.NoError(t, repo.AddVideoTags(tagged, []string{"beach-2024"}))
+	assert.NoError(t, repo.TrashVideo(trashed.ID))
+
+	results, err := repo.SearchVideos("BEACH", 10, 0)
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, beach.ID, results[0].Video.ID)
+		assert.Equal(t, "<mark>Beach</mark> day", results[0].TitleHighlight)
+		assert.Greater(t, results[0].Rank, results[1].Rank)
+
+		ids := []string{results[1].Video.ID, results[2].Video.ID}
+		assert.ElementsMatch(t, []string{sunset.ID, tagged.ID}, ids)
+		for _, result := range results[1:] {
+			if result.Video.ID == sunset.ID {
+				assert.Equal(t, "A walk on the <mark>beach</mark>", result.DescriptionHighlight)
+			} else {
+				assert.Len(t, result.Video.Tags, 1)
+			}
+		}
+	}
+}
+
+func TestSearchVideos_LikeFallbackEscapesWildcards(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	assert.NoError(t, repo.CreateVideo(&db.Video{Name: "a.mp4", Title: "100% real", Metadata: db.Metadata{}}))
+	assert.NoError(t, repo.CreateVideo(&db.Video{Name: "b.mp4", Title: "1000 real", Metadata: db.Metadata{}}))
+
+	results, err := repo.SearchVideos("100%", 10, 0)
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "<mark>100%</mark> real", results[0].TitleHighlight)
+	}
+}
+
+func TestSearchVideos_LikeFallbackKeepsTextWhenCaseChangesLength(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	assert.NoError(t, repo.CreateVideo(&db.Video{Name: "a.mp4", Title: "k", Metadata: db.Metadata{}}))
+
+	// the kelvin sign lowercases to a one byte "k"
+	results, err := repo.SearchVideos("\u212a", 10, 0)
+
+	assert.NoError(t, err)
+	if assert.Len(t, results, 1) {
+		assert.Equal(t, "k", results[0].TitleHighlight)
+	}
+}
+
+func TestListVideos_OriginalFilenamePrefix(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	clip := &db.Video{Name: "3fa2c1d4.mp4", OriginalFilename: "clip_1.mp4", Metadata: db.Metadata{}}
+	other := &db.Video{Name: "clip_2.mp4", OriginalFilename: "clipX2.mp4", Metadata: db.Metadata{}}
+	assert.NoError(t, repo.CreateVideo(clip))
+	assert.NoError(t, repo.CreateVideo(other))
+
+	videos, _, err := repo.ListVideos(VideoListParams{OriginalFilenamePrefix: "clip_", SortBy: "created_at", Limit: 10})
+
+	assert.NoError(t, err)
+	if assert.Len(t, videos, 1) {
+		assert.Equal(t, clip.ID, videos[0].ID)
+	}
+}
+
+func TestGetVideoLineage_WalksAncestorsAndDescendants(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	rawA := &db.Video{Name: "a.mp4", Metadata: db.Metadata{}}
+	rawB := &db.Video{Name: "b.mp4", Metadata: db.Metadata{}}
+	assert.NoError(t, repo.CreateVideo(rawA))
+	assert.NoError(t, repo.CreateVideo(rawB))
+
+	start, end := 1.0, 5.0
+	trimmed := &db.Video{Name: "trimmed.mp4", Metadata: db.Metadata{}, Sources: []db.VideoDerivation{
+		{SourceVideoID: rawA.ID, Operation: db.DERIVATION_TRIM, StartTS: &start, EndTS: &end},
+	}}
+	assert.NoError(t, repo.CreateVideo(trimmed))
+
+	merged := &db.Video{Name: "merged.mp4", Metadata: db.Metadata{}, Sources: []db.VideoDerivation{
+		{SourceVideoID: rawB.ID, Operation: db.DERIVATION_MERGE, Position: 0},
+		{SourceVideoID: trimmed.ID, Operation: db.DERIVATION_MERGE, Position: 1},
+	}}
+	assert.NoError(t, repo.CreateVideo(merged))
+
+	lineage, err := repo.GetVideoLineage(merged.ID)
+	assert.NoError(t, err)
+	assert.Len(t, lineage.Ancestors, 3)
+	assert.Empty(t, lineage.Descendants)
+	assert.Len(t, lineage.Videos, 4)
+
+	lineage, err = repo.GetVideoLineage(rawA.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, lineage.Ancestors)
+	if assert.Len(t, lineage.Descendants, 2) {
+		ids := []string{lineage.Descendants[0].VideoID, lineage.Descendants[1].VideoID}
+		assert.ElementsMatch(t, []string{trimmed.ID, merged.ID}, ids)
+	}
+
+	assert.NoError(t, repo.DeleteVideo(trimmed, func() error { return nil }))
+
+	lineage, err = repo.GetVideoLineage(merged.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, lineage.Ancestors, 3) {
+		sources := []string{}
+		for _, edge := range lineage.Ancestors {
+			sources = append(sources, edge.SourceVideoID)
+		}
+		assert.Contains(t, sources, rawA.ID)
+	}
+	assert.Len(t, lineage.Videos, 3)
+}
+
+func TestDeleteVideo_ClearsTags(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	tagged := &db.Video{Name: "a.mp4", Metadata: db.Metadata{}}
+	other := &db.Video{Name: "b.mp4", Metadata: db.Metadata{}}
+	assert.NoError(t, repo.CreateVideo(tagged))
+	assert.NoError(t, repo.CreateVideo(other))
+	assert.NoError(t, repo.AddVideoTags(tagged, []string{"beach", "sunset"}))
+	assert.NoError(t, repo.AddVideoTags(other, []string{"beach"}))
+
+	assert.NoError(t, repo.DeleteVideo(tagged, func() error { return nil }))
+
+	var rows int64
+	assert.NoError(t, testDB.Table("video_tags").Where("video_id = ?", tagged.ID).Count(&rows).Error)
+	assert.Zero(t, rows)
+	assert.NoError(t, testDB.Table("video_tags").Where("video_id = ?", other.ID).Count(&rows).Error)
+	assert.Equal(t, int64(1), rows)
+}
+
+func TestRemoveVideoTag_NotFound(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	tagged := &db.Video{Name: "a.mp4", Metadata: db.Metadata{}}
+	other := &db.Video{Name: "b.mp4", Metadata: db.Metadata{}}
+	assert.NoError(t, repo.CreateVideo(tagged))
+	assert.NoError(t, repo.CreateVideo(other))
+	assert.NoError(t, repo.AddVideoTags(tagged, []string{"beach"}))
+
+	assert.ErrorIs(t, repo.RemoveVideoTag(tagged, "sunset"), ErrTagNotFound)
+	assert.ErrorIs(t, repo.RemoveVideoTag(other, "beach"), ErrTagNotFound)
+
+	assert.NoError(t, repo.RemoveVideoTag(tagged, "beach"))
+	assert.ErrorIs(t, repo.RemoveVideoTag(tagged, "beach"), ErrTagNotFound)
+}
+
+func TestGetVideoBySHA256_IgnoresTrashedVideos(t *testing.T) {
+	testDB := setupTestDB(t)
+	repo := NewVideoRepository(testDB)
+
+	trashed := &db.Video{Name: "a.mp4", SHA256: "abc123", Metadata: db.Metadata{}}
+	live := &db.Video{Name: "b.mp4", SHA256: "abc123", Metadata: db.Metadata{}}
+	assert.NoError(t, repo.CreateVideo(trashed))
+	assert.NoError(t, repo.CreateVideo(live))
+	assert.NoError(t, repo.TrashVideo(trashed.ID))
+
+	video, err := repo.GetVideoBySHA256("abc123")
+	assert.NoError(t, err)
+	if assert.NotNil(t, video) {
+		assert.Equal(t, live.ID, video.ID)
+	}
+
+	video, err = repo.GetVideoBySHA256("def456")
+	assert.NoError(t, err)
+	assert.Nil(t, video)
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	TRIM_MODE_FAST     = "fast"
+	TRIM_MODE_ACCURATE = "accurate"
+	TRIM_MODE_SMART    = "smart"
+)
+
+// EncodingSettings are the ffmpeg encoder options used when a video has to be
+// re-encoded. CRF is the quality, lower is better, and Preset trades encoding
+// speed for file size, not every encoder has presets. Extension is the container
+// the encoders are written to.
+type EncodingSettings struct {
+	VideoCodec string
+	AudioCodec string
+	CRF        int
+	Preset     string
+	Extension  string
+}
+
+// TRIM_ENCODING is the default for accurate trims, and for the audio of smart trims.
+// A trim request can ask for another codec and CRF, see TrimOptions.
+var TRIM_ENCODING = EncodingSettings{
+	VideoCodec: "libx264",
+	AudioCodec: "aac",
+	CRF:        18,
+	Preset:     "medium",
+	Extension:  ".mp4",
+}
+
+// TRIM_VIDEO_CODECS are the codecs a trim request may re-encode to, with the encoder
+// used for each. Both take the CRF in the same range.
+var TRIM_VIDEO_CODECS = map[string]string{
+	"h264": "libx264",
+	"hevc": "libx265",
+}
+
+const (
+	MIN_TRIM_CRF = 0
+	MAX_TRIM_CRF = 51
+)
+
+// TrimOptions are how a trim is done. VideoCodec, one of TRIM_VIDEO_CODECS, and CRF
+// override TRIM_ENCODING for accurate trims. Smart trims keep the source's codec and
+// only take the CRF, fast trims take neither. Empty values keep the defaults.
+type TrimOptions struct {
+	Mode       string
+	VideoCodec string
+	CRF        *int
+}
+
+// encoding returns TRIM_ENCODING with the overrides of the options applied.
+func (o TrimOptions) encoding() (EncodingSettings, error) {
+	encoding := TRIM_ENCODING
+	if o.VideoCodec != "" {
+		encoder, ok := TRIM_VIDEO_CODECS[o.VideoCodec]
+		if !ok {
+			return EncodingSettings{}, fmt.Errorf("unsupported trim codec %q", o.VideoCodec)
+		}
+		encoding.VideoCodec = encoder
+	}
+	if o.CRF != nil {
+		if *o.CRF < MIN_TRIM_CRF || *o.CRF > MAX_TRIM_CRF {
+			return EncodingSettings{}, fmt.Errorf("trim crf %d is out of range", *o.CRF)
+		}
+		encoding.CRF = *o.CRF
+	}
+	return encoding, nil
+}
+
+func (e EncodingSettings) args() []string {
+	args := []string{"-c:v", e.VideoCodec, "-crf", strconv.Itoa(e.CRF)}
+	if e.Preset != "" {
+		args = append(args, "-preset", e.Preset)
+	}
+	args = append(args, "-c:a", e.AudioCodec)
+	if e.Extension == ".mp4" || e.Extension == ".mov" {
+		// move the index to the front so playback can start before the download ends
+		args = append(args, "-movflags", "+faststart")
+	}
+	return args
+}
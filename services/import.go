@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"path"
+	"syscall"
+	"time"
+)
+
+const (
+	IMPORT_TIMEOUT                 = 10 * time.Minute
+	IMPORT_CONNECT_TIMEOUT         = 10 * time.Second
+	IMPORT_RESPONSE_HEADER_TIMEOUT = 30 * time.Second
+	MAX_IMPORT_REDIRECTS           = 5
+	DEFAULT_IMPORT_FILENAME        = "import.mp4"
+)
+
+var ErrImportAddressNotAllowed = errors.New("import url resolves to a private address")
+
+// allowPrivateImportAddresses lets tests import from servers on localhost.
+var allowPrivateImportAddresses = false
+
+var importClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: IMPORT_CONNECT_TIMEOUT,
+			Control: checkImportAddress,
+		}).DialContext,
+		TLSHandshakeTimeout:   IMPORT_CONNECT_TIMEOUT,
+		ResponseHeaderTimeout: IMPORT_RESPONSE_HEADER_TIMEOUT,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= MAX_IMPORT_REDIRECTS {
+			return fmt.Errorf("stopped after %d redirects", MAX_IMPORT_REDIRECTS)
+		}
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return fmt.Errorf("redirect to unsupported scheme %s", req.URL.Scheme)
+		}
+		return nil
+	},
+}
+
+// DownloadVideo streams a remote video into staging, under the same size limit as
+// uploads. The whole download, redirects included, must finish within IMPORT_TIMEOUT.
+var DownloadVideo = func(ctx context.Context, rawURL string) (*StagedVideo, error) {
+	ctx, cancel := context.WithTimeout(ctx, IMPORT_TIMEOUT)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := importClient.Do(req)
+	if err != nil {
+		log.Printf("[service] failed to download video: %s", err.Error())
+		if errors.Is(err, ErrImportAddressNotAllowed) {
+			return nil, ErrImportAddressNotAllowed
+		}
+		return nil, fmt.Errorf("failed to download video")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("remote server responded with %s", resp.Status)
+	}
+	if resp.ContentLength > MAX_VIDEO_SIZE_MB*1024*1024 {
+		return nil, ErrVideoTooLarge
+	}
+
+	return StageVideo(resp.Body, importFilename(resp))
+}
+
+// importFilename takes the name from Content-Disposition, then from the last path
+// segment of the final url, and falls back to DEFAULT_IMPORT_FILENAME.
+func importFilename(resp *http.Response) string {
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		return params["filename"]
+	}
+
+	name := path.Base(resp.Request.URL.Path)
+	if name == "." || name == "/" || path.Ext(name) == "" {
+		return DEFAULT_IMPORT_FILENAME
+	}
+	return name
+}
+
+// checkImportAddress runs after name resolution, so a public hostname pointing at an
+// internal address is refused as well.
+func checkImportAddress(network, address string, _ syscall.RawConn) error {
+	if allowPrivateImportAddresses {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || ip.IsMulticast() {
+		return ErrImportAddressNotAllowed
+	}
+	return nil
+}
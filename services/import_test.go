@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func allowLocalImports(t *testing.T) {
+	allowPrivateImportAddresses = true
+	t.Cleanup(func() {
+		allowPrivateImportAddresses = false
+	})
+}
+
+func TestDownloadVideo_Success(t *testing.T) {
+	allowLocalImports(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/share/abc" {
+			http.Redirect(w, r, "/files/clip.mp4", http.StatusFound)
+			return
+		}
+		w.Write(testVideoData)
+	}))
+	defer server.Close()
+
+	stagedVideo, err := DownloadVideo(context.Background(), server.URL+"/share/abc")
+	assert.NoError(t, err)
+	defer DiscardStagedVideo(stagedVideo)
+
+	assert.Equal(t, "clip.mp4", stagedVideo.Filename)
+	assert.Equal(t, int64(len(testVideoData)), stagedVideo.Size)
+	data, _ := os.ReadFile(stagedVideo.Path)
+	assert.Equal(t, testVideoData, data)
+}
+
+func TestDownloadVideo_FilenameFromContentDisposition(t *testing.T) {
+	allowLocalImports(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Disposition", `attachment; filename="holiday.mov"`)
+		w.Write(testVideoData)
+	}))
+	defer server.Close()
+
+	stagedVideo, err := DownloadVideo(context.Background(), server.URL+"/download?id=1")
+	assert.NoError(t, err)
+	defer DiscardStagedVideo(stagedVideo)
+
+	assert.Equal(t, "holiday.mov", stagedVideo.Filename)
+}
+
+func TestDownloadVideo_Failures(t *testing.T) {
+	allowLocalImports(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/large":
+			w.Header().Set("Content-Length", "999999999999")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, target := range []string{"/loop", "/large", "/missing"} {
+		stagedVideo, err := DownloadVideo(context.Background(), server.URL+target)
+		assert.Error(t, err, target)
+		assert.Nil(t, stagedVideo, target)
+	}
+}
+
+func TestDownloadVideo_RefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(testVideoData)
+	}))
+	defer server.Close()
+
+	stagedVideo, err := DownloadVideo(context.Background(), server.URL+"/clip.mp4")
+	assert.ErrorIs(t, err, ErrImportAddressNotAllowed)
+	assert.Nil(t, stagedVideo)
+}
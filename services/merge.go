@@ -0,0 +1,209 @@
+package services
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/3ssalunke/videoverse/db"
+)
+
+const (
+	DEFAULT_MERGE_FRAME_RATE  = 30
+	DEFAULT_MERGE_SAMPLE_RATE = 48000
+	DEFAULT_MERGE_CHANNELS    = 2
+)
+
+// MERGE_CHANNEL_LAYOUTS are the audio channel counts a merge can be normalised to.
+var MERGE_CHANNEL_LAYOUTS = map[int]string{
+	1: "mono",
+	2: "stereo",
+	6: "5.1",
+	8: "7.1",
+}
+
+// MERGE_ENCODING is used when merged videos have to be re-encoded.
+var MERGE_ENCODING = EncodingSettings{
+	VideoCodec: "libx264",
+	AudioCodec: "aac",
+	CRF:        18,
+	Preset:     "medium",
+	Extension:  ".mp4",
+}
+
+// MERGE_COPY_CODECS are the codecs each output container can take by stream copy.
+// Inputs in any other codec are re-encoded, even when they match each other.
+var MERGE_COPY_CODECS = map[string]map[string]bool{
+	".mp4": {
+		"h264": true, "hevc": true, "av1": true, "mpeg4": true,
+		"aac": true, "mp3": true, "ac3": true, "eac3": true, "alac": true,
+	},
+}
+
+// MergeTarget is the profile merged videos are normalised to. Zero fields are taken
+// from the first video.
+type MergeTarget struct {
+	Width      int
+	Height     int
+	FrameRate  float64
+	SampleRate int
+	Channels   int
+}
+
+type mergeInput struct {
+	Path     string
+	Duration float64
+	Media    db.VideoMedia
+}
+
+func (i mergeInput) hasAudio() bool {
+	return i.Media.AudioCodec != ""
+}
+
+func probeMergeInputs(videoPaths []string) ([]mergeInput, error) {
+	inputs := make([]mergeInput, len(videoPaths))
+	for i, videoPath := range videoPaths {
+		probe, err := probeVideo(videoPath)
+		if err != nil {
+			log.Printf("[service] failed to probe video: %s", err.Error())
+			return nil, fmt.Errorf("failed to probe video %s", videoPath)
+		}
+		duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+		if err != nil {
+			log.Printf("[service] failed to get video duration: %s", err.Error())
+			return nil, fmt.Errorf("failed to get video duration of %s", videoPath)
+		}
+		inputs[i] = mergeInput{Path: videoPath, Duration: duration, Media: probe.media("")}
+	}
+
+	return inputs, nil
+}
+
+// mergeCompatible reports whether the inputs can be joined by copying their streams
+// into a file of extension ext, which needs them to agree on everything the decoder
+// is set up with, to be in codecs the container holds, and to already match any
+// target that was asked for.
+func mergeCompatible(inputs []mergeInput, target MergeTarget, ext string) bool {
+	first := inputs[0].Media
+	codecs := MERGE_COPY_CODECS[ext]
+	if !codecs[first.VideoCodec] || (first.AudioCodec != "" && !codecs[first.AudioCodec]) {
+		return false
+	}
+	for _, input := range inputs[1:] {
+		media := input.Media
+		if media.VideoCodec != first.VideoCodec || media.Width != first.Width || media.Height != first.Height ||
+			media.FrameRate != first.FrameRate || media.PixelFormat != first.PixelFormat || media.Rotation != first.Rotation ||
+			media.AudioCodec != first.AudioCodec || media.SampleRate != first.SampleRate || media.Channels != first.Channels {
+			return false
+		}
+	}
+
+	return (target.Width == 0 || target.Width == first.Width) &&
+		(target.Height == 0 || target.Height == first.Height) &&
+		(target.FrameRate == 0 || target.FrameRate == first.FrameRate) &&
+		(target.SampleRate == 0 || target.SampleRate == first.SampleRate) &&
+		(target.Channels == 0 || target.Channels == first.Channels)
+}
+
+// resolveMergeTarget fills the fields the request left out from the first video, as
+// it is displayed, and rounds the picture size to the even numbers encoders need.
+func resolveMergeTarget(target MergeTarget, inputs []mergeInput) MergeTarget {
+	first := inputs[0].Media
+	width, height := first.Width, first.Height
+	if first.Rotation == 90 || first.Rotation == 270 {
+		width, height = height, width
+	}
+
+	if target.Width == 0 {
+		target.Width = width
+	}
+	if target.Height == 0 {
+		target.Height = height
+	}
+	if target.FrameRate == 0 {
+		target.FrameRate = first.FrameRate
+	}
+	if target.FrameRate == 0 {
+		target.FrameRate = DEFAULT_MERGE_FRAME_RATE
+	}
+	if target.SampleRate == 0 {
+		target.SampleRate = first.SampleRate
+	}
+	if target.SampleRate == 0 {
+		target.SampleRate = DEFAULT_MERGE_SAMPLE_RATE
+	}
+	if _, ok := MERGE_CHANNEL_LAYOUTS[target.Channels]; !ok {
+		target.Channels = first.Channels
+	}
+	if _, ok := MERGE_CHANNEL_LAYOUTS[target.Channels]; !ok {
+		target.Channels = DEFAULT_MERGE_CHANNELS
+	}
+
+	target.Width -= target.Width % 2
+	target.Height -= target.Height % 2
+	return target
+}
+
+// mergeFilterGraph scales every input to fit the target, pads it to the exact size
+// and brings it to the target frame rate, then resamples its audio to the target
+// layout. Inputs without audio get silence of their length, so the concat filter
+// sees the same streams from each. When no input has audio the output has none.
+func mergeFilterGraph(inputs []mergeInput, target MergeTarget) string {
+	withAudio := false
+	for _, input := range inputs {
+		withAudio = withAudio || input.hasAudio()
+	}
+	layout := MERGE_CHANNEL_LAYOUTS[target.Channels]
+
+	var graph, concatInputs strings.Builder
+	for i, input := range inputs {
+		fmt.Fprintf(&graph,
+			"[%d:v:0]scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=%s,format=yuv420p[v%d];",
+			i, target.Width, target.Height, target.Width, target.Height, strconv.FormatFloat(target.FrameRate, 'f', -1, 64), i)
+		fmt.Fprintf(&concatInputs, "[v%d]", i)
+
+		if !withAudio {
+			continue
+		}
+		if input.hasAudio() {
+			fmt.Fprintf(&graph, "[%d:a:0]aresample=%d,aformat=sample_fmts=fltp:channel_layouts=%s[a%d];",
+				i, target.SampleRate, layout, i)
+		} else {
+			fmt.Fprintf(&graph, "anullsrc=r=%d:cl=%s,atrim=duration=%s[a%d];",
+				target.SampleRate, layout, formatSeconds(input.Duration), i)
+		}
+		fmt.Fprintf(&concatInputs, "[a%d]", i)
+	}
+
+	audioStreams := 0
+	if withAudio {
+		audioStreams = 1
+	}
+	fmt.Fprintf(&graph, "%sconcat=n=%d:v=1:a=%d[v]", concatInputs.String(), len(inputs), audioStreams)
+	if withAudio {
+		graph.WriteString("[a]")
+	}
+
+	return graph.String()
+}
+
+// normaliseMergeVideos joins inputs that differ in codec, size, frame rate or audio
+// layout, re-encoding them all to target with MERGE_ENCODING.
+func normaliseMergeVideos(inputs []mergeInput, outputPath string, target MergeTarget) error {
+	var args []string
+	withAudio := false
+	for _, input := range inputs {
+		args = append(args, "-i", input.Path)
+		withAudio = withAudio || input.hasAudio()
+	}
+
+	args = append(args, "-filter_complex", mergeFilterGraph(inputs, target), "-map", "[v]")
+	if withAudio {
+		args = append(args, "-map", "[a]")
+	}
+	args = append(args, MERGE_ENCODING.args()...)
+	args = append(args, outputPath)
+
+	return runFFmpeg("merge videos", args...)
+}
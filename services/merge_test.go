@@ -0,0 +1,124 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	hdProbe        = `{"streams":[{"codec_type":"video","codec_name":"h264","width":1920,"height":1080,"avg_frame_rate":"30/1","pix_fmt":"yuv420p"},{"codec_type":"audio","codec_name":"aac","sample_rate":"48000","channels":2}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"10.0"}}`
+	phoneProbe     = `{"streams":[{"codec_type":"video","codec_name":"hevc","width":1280,"height":720,"avg_frame_rate":"60/1","pix_fmt":"yuv420p","tags":{"rotate":"90"}},{"codec_type":"audio","codec_name":"aac","sample_rate":"44100","channels":1}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"12.5"}}`
+	silentWebProbe = `{"streams":[{"codec_type":"video","codec_name":"vp9","width":640,"height":360,"avg_frame_rate":"25/1","pix_fmt":"yuv420p"}],"format":{"format_name":"matroska,webm","duration":"4.0"}}`
+)
+
+// mergeOutput answers ffprobe with the probe given for the probed path.
+func mergeOutput(probes map[string]string) func(command string, args []string) string {
+	return func(command string, args []string) string {
+		return probes[args[len(args)-1]]
+	}
+}
+
+func TestMergeCompatible(t *testing.T) {
+	hd := mergeInput{Media: db.VideoMedia{VideoCodec: "h264", Width: 1920, Height: 1080, FrameRate: 30, PixelFormat: "yuv420p", AudioCodec: "aac", SampleRate: 48000, Channels: 2}}
+	silent := hd
+	silent.Media.AudioCodec, silent.Media.SampleRate, silent.Media.Channels = "", 0, 0
+	fast := hd
+	fast.Media.FrameRate = 60
+
+	web := hd
+	web.Media.VideoCodec, web.Media.AudioCodec = "vp8", "vorbis"
+
+	assert.True(t, mergeCompatible([]mergeInput{hd, hd}, MergeTarget{}, ".mp4"))
+	assert.True(t, mergeCompatible([]mergeInput{hd, hd}, MergeTarget{Width: 1920, Channels: 2}, ".mp4"))
+	assert.True(t, mergeCompatible([]mergeInput{silent, silent}, MergeTarget{}, ".mp4"))
+	assert.False(t, mergeCompatible([]mergeInput{hd, hd}, MergeTarget{Width: 1280, Height: 720}, ".mp4"))
+	assert.False(t, mergeCompatible([]mergeInput{hd, silent}, MergeTarget{}, ".mp4"))
+	assert.False(t, mergeCompatible([]mergeInput{hd, fast}, MergeTarget{}, ".mp4"))
+	// matching inputs still need a container that can hold their codecs
+	assert.False(t, mergeCompatible([]mergeInput{web, web}, MergeTarget{}, ".mp4"))
+	assert.False(t, mergeCompatible([]mergeInput{hd, hd}, MergeTarget{}, ".mkv"))
+}
+
+func TestResolveMergeTarget(t *testing.T) {
+	rotated := mergeInput{Media: db.VideoMedia{Width: 1281, Height: 721, FrameRate: 59.94, Rotation: 90, AudioCodec: "aac", SampleRate: 44100, Channels: 1}}
+	assert.Equal(t, MergeTarget{Width: 720, Height: 1280, FrameRate: 59.94, SampleRate: 44100, Channels: 1},
+		resolveMergeTarget(MergeTarget{}, []mergeInput{rotated}))
+
+	silent := mergeInput{Media: db.VideoMedia{Width: 640, Height: 360}}
+	assert.Equal(t, MergeTarget{Width: 1280, Height: 720, FrameRate: 30, SampleRate: 48000, Channels: 2},
+		resolveMergeTarget(MergeTarget{Width: 1280, Height: 720}, []mergeInput{silent}))
+}
+
+func TestMergeFilterGraph(t *testing.T) {
+	inputs := []mergeInput{
+		{Media: db.VideoMedia{AudioCodec: "aac"}},
+		{Duration: 4, Media: db.VideoMedia{}},
+	}
+	target := MergeTarget{Width: 1280, Height: 720, FrameRate: 29.97, SampleRate: 48000, Channels: 2}
+
+	assert.Equal(t,
+		"[0:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=29.97,format=yuv420p[v0];"+
+			"[0:a:0]aresample=48000,aformat=sample_fmts=fltp:channel_layouts=stereo[a0];"+
+			"[1:v:0]scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:(ow-iw)/2:(oh-ih)/2,setsar=1,fps=29.97,format=yuv420p[v1];"+
+			"anullsrc=r=48000:cl=stereo,atrim=duration=4.000000[a1];"+
+			"[v0][a0][v1][a1]concat=n=2:v=1:a=1[v][a]",
+		mergeFilterGraph(inputs, target))
+
+	assert.True(t, strings.HasSuffix(mergeFilterGraph(inputs[1:], target), "[v0]concat=n=1:v=1:a=0[v]"))
+}
+
+func TestMergeVideos_CopiesCompatibleInputs(t *testing.T) {
+	probe := mergeOutput(map[string]string{"a.mp4": hdProbe, "b.mp4": hdProbe})
+	var list []byte
+	runs := fakeCommands(t, func(command string, args []string) string {
+		if command == "ffmpeg" {
+			list, _ = os.ReadFile(args[5])
+		}
+		return probe(command, args)
+	})
+
+	err := MergeVideos([]string{"a.mp4", "b.mp4"}, "merged.mp4", MergeTarget{})
+	assert.NoError(t, err)
+	assert.Len(t, *runs, 1)
+	args := (*runs)[0]
+	assert.Equal(t, []string{"-f", "concat", "-safe", "0", "-i"}, args[:5])
+	assert.Equal(t, []string{"-c", "copy", "merged.mp4"}, args[6:])
+
+	// each merge lists its inputs in a file of its own, removed afterwards
+	a, _ := filepath.Abs("a.mp4")
+	b, _ := filepath.Abs("b.mp4")
+	assert.Equal(t, "file '"+filepath.ToSlash(a)+"'\nfile '"+filepath.ToSlash(b)+"'\n", string(list))
+	assert.Equal(t, filepath.Clean(STAGING_DIR), filepath.Dir(args[5]))
+	_, err = os.Stat(args[5])
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMergeVideos_NormalisesCodecsTheContainerCanNotHold(t *testing.T) {
+	runs := fakeCommands(t, mergeOutput(map[string]string{"a.webm": silentWebProbe, "b.webm": silentWebProbe}))
+
+	err := MergeVideos([]string{"a.webm", "b.webm"}, "merged.mp4", MergeTarget{})
+	assert.NoError(t, err)
+	if assert.Len(t, *runs, 1) {
+		assert.Contains(t, strings.Join((*runs)[0], " "), "-filter_complex ")
+	}
+}
+
+func TestMergeVideos_NormalisesMixedInputs(t *testing.T) {
+	runs := fakeCommands(t, mergeOutput(map[string]string{"a.mp4": hdProbe, "b.mov": phoneProbe, "c.webm": silentWebProbe}))
+
+	err := MergeVideos([]string{"a.mp4", "b.mov", "c.webm"}, "merged.mp4", MergeTarget{SampleRate: 44100})
+	assert.NoError(t, err)
+	assert.Len(t, *runs, 1)
+
+	args := strings.Join((*runs)[0], " ")
+	assert.True(t, strings.HasPrefix(args, "-i a.mp4 -i b.mov -i c.webm -filter_complex "))
+	assert.Contains(t, args, "scale=1920:1080:force_original_aspect_ratio=decrease")
+	assert.Contains(t, args, "[1:a:0]aresample=44100,aformat=sample_fmts=fltp:channel_layouts=stereo[a1]")
+	assert.Contains(t, args, "anullsrc=r=44100:cl=stereo,atrim=duration=4.000000[a2]")
+	assert.Contains(t, args, "-map [v] -map [a] -c:v libx264 -crf 18 -preset medium -c:a aac -movflags +faststart merged.mp4")
+}
@@ -0,0 +1,186 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SIGNING_KEYS_ENV holds every secret a signed url may have been minted with, as
+// comma separated id=secret pairs, and SIGNING_KEY_ID_ENV the id of the one new urls
+// are signed with. To rotate, add a new key, point the id at it and drop the old one
+// once the urls signed with it have expired.
+const (
+	SIGNING_KEYS_ENV       = "SIGNING_KEYS"
+	SIGNING_KEY_ID_ENV     = "SIGNING_KEY_ID"
+	MIN_SIGNING_KEY_LENGTH = 32
+)
+
+// SIGNING_KEYS and CURRENT_SIGNING_KEY_ID are set by LoadSigningKeys. Until then no
+// url can be signed or verified.
+var (
+	SIGNING_KEYS           = map[string]string{}
+	CURRENT_SIGNING_KEY_ID = ""
+)
+
+var (
+	ErrSignedURLInvalid = errors.New("invalid signed url")
+	ErrSignedURLExpired = errors.New("signed url has expired")
+	ErrNoSigningKeys    = errors.New("no signing keys configured")
+)
+
+// LoadSigningKeys reads the signing keys from the environment. When none are set
+// it returns ErrNoSigningKeys and signed urls stay disabled.
+func LoadSigningKeys() error {
+	keys, err := parseSigningKeys(os.Getenv(SIGNING_KEYS_ENV), os.Getenv(SIGNING_KEY_ID_ENV))
+	if err != nil {
+		return err
+	}
+
+	SIGNING_KEYS, CURRENT_SIGNING_KEY_ID = keys, os.Getenv(SIGNING_KEY_ID_ENV)
+	return nil
+}
+
+func parseSigningKeys(value, currentKeyID string) (map[string]string, error) {
+	if strings.TrimSpace(value) == "" {
+		return nil, ErrNoSigningKeys
+	}
+
+	keys := map[string]string{}
+	for i, pair := range strings.Split(value, ",") {
+		// never echo the pair, it holds the secret
+		id, secret, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("malformed signing key at position %d, expected id=secret", i+1)
+		}
+		if _, ok := keys[id]; ok {
+			return nil, fmt.Errorf("signing key %s is set twice", id)
+		}
+		if len(secret) < MIN_SIGNING_KEY_LENGTH {
+			return nil, fmt.Errorf("signing key %s is shorter than %d bytes", id, MIN_SIGNING_KEY_LENGTH)
+		}
+		keys[id] = secret
+	}
+
+	if _, ok := keys[currentKeyID]; !ok {
+		return nil, fmt.Errorf("current signing key %q not found in %s", currentKeyID, SIGNING_KEYS_ENV)
+	}
+
+	return keys, nil
+}
+
+type ByteRange struct {
+	Start int64
+	End   int64
+}
+
+// SignedVideo is what a signed url grants access to. Filename is the storage key of
+// the video file, its path inside UPLOAD_DIR.
+type SignedVideo struct {
+	VideoID   string
+	Filename  string
+	ExpiresAt time.Time
+	Range     *ByteRange
+}
+
+// SignVideoURL returns the query parameters that authorise playback of the given
+// video file until expiresAt, optionally limited to an inclusive byte range.
+func SignVideoURL(video SignedVideo) (url.Values, error) {
+	if len(SIGNING_KEYS) == 0 {
+		return nil, ErrNoSigningKeys
+	}
+
+	secret, ok := SIGNING_KEYS[CURRENT_SIGNING_KEY_ID]
+	if !ok {
+		return nil, fmt.Errorf("signing key %s not found", CURRENT_SIGNING_KEY_ID)
+	}
+
+	query := url.Values{}
+	query.Set("f", video.Filename)
+	query.Set("exp", strconv.FormatInt(video.ExpiresAt.Unix(), 10))
+	if video.Range != nil {
+		query.Set("range", fmt.Sprintf("%d-%d", video.Range.Start, video.Range.End))
+	}
+	query.Set("kid", CURRENT_SIGNING_KEY_ID)
+	query.Set("sig", signature(secret, video.VideoID, query))
+
+	return query, nil
+}
+
+// VerifyVideoURL checks the signature and expiry of a signed url without touching
+// the database, and returns what the url grants access to.
+func VerifyVideoURL(videoID string, query url.Values) (*SignedVideo, error) {
+	secret, ok := SIGNING_KEYS[query.Get("kid")]
+	if !ok {
+		return nil, ErrSignedURLInvalid
+	}
+
+	expected := signature(secret, videoID, query)
+	if !hmac.Equal([]byte(expected), []byte(query.Get("sig"))) {
+		return nil, ErrSignedURLInvalid
+	}
+
+	expiresAt, err := strconv.ParseInt(query.Get("exp"), 10, 64)
+	if err != nil {
+		return nil, ErrSignedURLInvalid
+	}
+
+	video := &SignedVideo{
+		VideoID:   videoID,
+		Filename:  query.Get("f"),
+		ExpiresAt: time.Unix(expiresAt, 0),
+	}
+	if !ValidStorageKey(video.Filename) {
+		return nil, ErrSignedURLInvalid
+	}
+
+	if rangeParam := query.Get("range"); rangeParam != "" {
+		byteRange, err := parseByteRange(rangeParam)
+		if err != nil {
+			return nil, ErrSignedURLInvalid
+		}
+		video.Range = byteRange
+	}
+
+	if !time.Now().Before(video.ExpiresAt) {
+		return nil, ErrSignedURLExpired
+	}
+
+	return video, nil
+}
+
+func signature(secret, videoID string, query url.Values) string {
+	payload := strings.Join([]string{videoID, query.Get("f"), query.Get("exp"), query.Get("range"), query.Get("kid")}, "\n")
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func parseByteRange(value string) (*ByteRange, error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return nil, fmt.Errorf("malformed byte range %q", value)
+	}
+
+	startByte, err := strconv.ParseInt(start, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	endByte, err := strconv.ParseInt(end, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	if startByte < 0 || endByte < startByte {
+		return nil, fmt.Errorf("malformed byte range %q", value)
+	}
+
+	return &ByteRange{Start: startByte, End: endByte}, nil
+}
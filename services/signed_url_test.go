@@ -0,0 +1,143 @@
+package services
+
+import (
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSigningKey = "test-signing-key-0123456789abcdef"
+
+func useTestSigningKeys(t *testing.T) {
+	SIGNING_KEYS, CURRENT_SIGNING_KEY_ID = map[string]string{"k1": testSigningKey}, "k1"
+	t.Cleanup(func() {
+		SIGNING_KEYS, CURRENT_SIGNING_KEY_ID = map[string]string{}, ""
+	})
+}
+
+func TestLoadSigningKeys(t *testing.T) {
+	t.Cleanup(func() {
+		SIGNING_KEYS, CURRENT_SIGNING_KEY_ID = map[string]string{}, ""
+	})
+
+	t.Setenv(SIGNING_KEYS_ENV, "k1="+testSigningKey+", k2=rotated-signing-key-0123456789abcdef")
+	t.Setenv(SIGNING_KEY_ID_ENV, "k2")
+	assert.NoError(t, LoadSigningKeys())
+	assert.Equal(t, "k2", CURRENT_SIGNING_KEY_ID)
+	assert.Equal(t, map[string]string{"k1": testSigningKey, "k2": "rotated-signing-key-0123456789abcdef"}, SIGNING_KEYS)
+
+	t.Setenv(SIGNING_KEYS_ENV, "")
+	assert.ErrorIs(t, LoadSigningKeys(), ErrNoSigningKeys)
+
+	for _, keys := range []string{"k1", "=" + testSigningKey, "k2=short", "k2=" + testSigningKey + ",k2=" + testSigningKey, "k1=" + testSigningKey} {
+		t.Setenv(SIGNING_KEYS_ENV, keys)
+		err := LoadSigningKeys()
+		if assert.Error(t, err, keys) {
+			assert.NotContains(t, err.Error(), testSigningKey)
+		}
+	}
+}
+
+func TestSignVideoURL_NoSigningKeys(t *testing.T) {
+	_, err := SignVideoURL(SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	assert.ErrorIs(t, err, ErrNoSigningKeys)
+
+	query := url.Values{}
+	query.Set("f", "test.mp4")
+	query.Set("exp", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	query.Set("sig", signature("", "video-id", query))
+	_, err = VerifyVideoURL("video-id", query)
+	assert.ErrorIs(t, err, ErrSignedURLInvalid)
+}
+
+func TestSignVideoURL_RoundTrip(t *testing.T) {
+	useTestSigningKeys(t)
+	query, err := SignVideoURL(SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+		Range:     &ByteRange{Start: 10, End: 99},
+	})
+	assert.NoError(t, err)
+
+	video, err := VerifyVideoURL("video-id", query)
+	assert.NoError(t, err)
+	assert.Equal(t, "test.mp4", video.Filename)
+	assert.Equal(t, &ByteRange{Start: 10, End: 99}, video.Range)
+}
+
+func TestVerifyVideoURL_Tampered(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := SignVideoURL(SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	_, err := VerifyVideoURL("other-video-id", query)
+	assert.ErrorIs(t, err, ErrSignedURLInvalid)
+
+	query.Set("f", "other.mp4")
+	_, err = VerifyVideoURL("video-id", query)
+	assert.ErrorIs(t, err, ErrSignedURLInvalid)
+}
+
+func TestVerifyVideoURL_StorageKey(t *testing.T) {
+	useTestSigningKeys(t)
+	sign := func(filename string) (*SignedVideo, error) {
+		query := url.Values{}
+		query.Set("f", filename)
+		query.Set("exp", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+		query.Set("kid", CURRENT_SIGNING_KEY_ID)
+		query.Set("sig", signature(SIGNING_KEYS[CURRENT_SIGNING_KEY_ID], "video-id", query))
+		return VerifyVideoURL("video-id", query)
+	}
+
+	video, err := sign("3f/a2/3fa2c1d4-5b6e-4f70-8a9b-0c1d2e3f4a5b.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, "3f/a2/3fa2c1d4-5b6e-4f70-8a9b-0c1d2e3f4a5b.mp4", video.Filename)
+
+	// a validly signed key must still stay inside the store
+	for _, filename := range []string{"../secrets.env", "/etc/passwd", "3f/../../x.mp4", `3f\a2.mp4`, ".staging/upload-1", ""} {
+		_, err := sign(filename)
+		assert.ErrorIs(t, err, ErrSignedURLInvalid, filename)
+	}
+}
+
+func TestVerifyVideoURL_Expired(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := SignVideoURL(SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(-time.Minute),
+	})
+
+	_, err := VerifyVideoURL("video-id", query)
+	assert.ErrorIs(t, err, ErrSignedURLExpired)
+}
+
+func TestVerifyVideoURL_KeyRotation(t *testing.T) {
+	useTestSigningKeys(t)
+	query, _ := SignVideoURL(SignedVideo{
+		VideoID:   "video-id",
+		Filename:  "test.mp4",
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+
+	SIGNING_KEYS["k2"] = "rotated-signing-key-0123456789abcdef"
+	CURRENT_SIGNING_KEY_ID = "k2"
+
+	_, err := VerifyVideoURL("video-id", query)
+	assert.NoError(t, err)
+
+	delete(SIGNING_KEYS, "k1")
+	_, err = VerifyVideoURL("video-id", query)
+	assert.ErrorIs(t, err, ErrSignedURLInvalid)
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// keyframeSeekOffset is added when seeking to a keyframe, because ffprobe rounds
+	// timestamps and a seek that lands just before one snaps to the keyframe before.
+	keyframeSeekOffset = 0.0005
+	// keyframeTolerance is how close to a keyframe a cut counts as on it.
+	keyframeTolerance = 0.001
+)
+
+// smartCutCodec is how the ends of a smart trim are re-encoded for a source codec so
+// they splice with the stream copied middle. Profiles maps the profile names ffprobe
+// reports to the encoder's, sources in any other profile are trimmed accurately.
+type smartCutCodec struct {
+	Encoder  string
+	Profiles map[string]string
+	// Level turns the level ffprobe reports into encoder options
+	Level func(level int) []string
+	// AnnexB moves the parameter sets of copied packets in-band
+	AnnexB string
+	// Tag is the mp4 sample entry that allows parameter sets to change in-band
+	Tag string
+}
+
+var smartCutCodecs = map[string]smartCutCodec{
+	"h264": {
+		Encoder: "libx264",
+		Profiles: map[string]string{
+			"Constrained Baseline": "baseline",
+			"Main":                 "main",
+			"High":                 "high",
+		},
+		Level: func(level int) []string {
+			return []string{"-level:v", fmt.Sprintf("%d.%d", level/10, level%10)}
+		},
+		AnnexB: "h264_mp4toannexb",
+		Tag:    "avc3",
+	},
+	"hevc": {
+		Encoder: "libx265",
+		Profiles: map[string]string{
+			"Main": "main",
+		},
+		Level: func(level int) []string {
+			return []string{"-x265-params", "level-idc=" + strconv.FormatFloat(float64(level)/30, 'f', 1, 64)}
+		},
+		AnnexB: "hevc_mp4toannexb",
+		Tag:    "hev1",
+	},
+}
+
+// trimSegment is a piece of a smart trim, either stream copied or re-encoded.
+type trimSegment struct {
+	Start float64
+	End   float64
+	Copy  bool
+}
+
+// planSmartCut splits startTs to endTs at the first and last keyframes inside it, so
+// only the partial groups of pictures at either end need re-encoding. It returns nil
+// when the range does not span two keyframes, there is then nothing to copy.
+func planSmartCut(keyframes []float64, startTs, endTs float64) []trimSegment {
+	first, last := -1.0, -1.0
+	for _, keyframe := range keyframes {
+		if keyframe < startTs-keyframeTolerance || keyframe > endTs+keyframeTolerance {
+			continue
+		}
+		if first < 0 {
+			first = keyframe
+		}
+		last = keyframe
+	}
+	if first < 0 || last-first < keyframeTolerance {
+		return nil
+	}
+
+	var segments []trimSegment
+	if first-startTs > keyframeTolerance {
+		segments = append(segments, trimSegment{Start: startTs, End: first})
+	}
+	segments = append(segments, trimSegment{Start: first, End: last, Copy: true})
+	if endTs-last > keyframeTolerance {
+		segments = append(segments, trimSegment{Start: last, End: endTs})
+	}
+
+	return segments
+}
+
+// probeKeyframes lists the timestamps of the keyframes of the first video stream.
+// It reads packet flags only, so nothing is decoded.
+func probeKeyframes(filePath string) ([]float64, error) {
+	cmd := execCommand("ffprobe", "-v", "error",
+		"-select_streams", "v:0",
+		"-show_entries", "packet=pts_time,flags",
+		"-of", "json", filePath)
+
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("error running ffprobe: %s %s", errBuf.String(), err.Error())
+	}
+
+	var packets struct {
+		Packets []struct {
+			PtsTime string `json:"pts_time"`
+			Flags   string `json:"flags"`
+		} `json:"packets"`
+	}
+	if err := json.Unmarshal(outBuf.Bytes(), &packets); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	var keyframes []float64
+	for _, packet := range packets.Packets {
+		if !strings.Contains(packet.Flags, "K") {
+			continue
+		}
+		pts, err := strconv.ParseFloat(packet.PtsTime, 64)
+		if err != nil {
+			continue
+		}
+		keyframes = append(keyframes, pts)
+	}
+
+	return keyframes, nil
+}
+
+// smartTrimVideo gives the frame accuracy of an accurate trim at close to the speed
+// of a fast one. The video between the first and last keyframes in the range is
+// stream copied and only the ends are re-encoded, with the source's codec, profile,
+// level and pixel format so the pieces can be spliced. The pieces are cut to MPEG-TS,
+// where every keyframe carries its parameter sets in-band, so the copied middle
+// never decodes against the parameter sets of a re-encoded end. The audio is
+// re-encoded over the whole range, which is cheap and keeps it free of gaps at the
+// splices. Sources in other codecs or profiles, or ranges too short to contain two
+// keyframes, are trimmed accurately instead.
+func smartTrimVideo(videoPath, outputPath string, startTs, endTs float64, encoding EncodingSettings) error {
+	probe, err := probeVideo(videoPath)
+	if err != nil {
+		log.Printf("[service] failed to probe video: %s", err.Error())
+		return err
+	}
+	media := probe.media("")
+
+	codec, ok := smartCutCodecs[media.VideoCodec]
+	if !ok {
+		log.Printf("[service] can not smart cut %s video, trimming accurately", media.VideoCodec)
+		return accurateTrimVideo(videoPath, outputPath, startTs, endTs, encoding)
+	}
+	encodeArgs, ok := smartCutEncodeArgs(codec, probe, media.PixelFormat, encoding)
+	if !ok {
+		profile, level := probe.codecProfile()
+		log.Printf("[service] can not match %s profile %q level %d, trimming accurately", media.VideoCodec, profile, level)
+		return accurateTrimVideo(videoPath, outputPath, startTs, endTs, encoding)
+	}
+
+	keyframes, err := probeKeyframes(videoPath)
+	if err != nil {
+		log.Printf("[service] failed to index keyframes: %s", err.Error())
+		return err
+	}
+
+	segments := planSmartCut(keyframes, startTs, endTs)
+	if segments == nil {
+		log.Printf("[service] no keyframes to copy between %.3f and %.3f, trimming accurately", startTs, endTs)
+		return accurateTrimVideo(videoPath, outputPath, startTs, endTs, encoding)
+	}
+
+	if err := os.MkdirAll(STAGING_DIR, os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
+		return err
+	}
+	workDir, err := os.MkdirTemp(STAGING_DIR, "trim-*")
+	if err != nil {
+		log.Printf("[service] failed to create trim directory: %s", err.Error())
+		return err
+	}
+	defer os.RemoveAll(workDir)
+
+	var segmentList strings.Builder
+	for i, segment := range segments {
+		segmentPath := filepath.Join(workDir, fmt.Sprintf("segment-%d.ts", i))
+		if err := runFFmpeg("cut trim segment", segmentArgs(videoPath, segmentPath, segment, codec, encodeArgs)...); err != nil {
+			return err
+		}
+		// the list is read by ffmpeg relative to its own directory
+		fmt.Fprintf(&segmentList, "file %s\n", filepath.Base(segmentPath))
+	}
+
+	listPath := filepath.Join(workDir, "segments.txt")
+	if err := os.WriteFile(listPath, []byte(segmentList.String()), 0o644); err != nil {
+		log.Printf("[service] failed to write segment list: %s", err.Error())
+		return err
+	}
+
+	args := []string{
+		"-f", "concat", "-safe", "0", "-i", listPath,
+		"-ss", formatSeconds(startTs), "-t", formatSeconds(endTs - startTs), "-i", videoPath,
+		"-map", "0:v:0", "-map", "1:a:0?",
+		"-c:v", "copy", "-c:a", encoding.AudioCodec,
+	}
+	if ext := filepath.Ext(outputPath); ext == ".mp4" || ext == ".mov" {
+		// avc1 and hvc1 take the parameter sets from the header alone, which only has
+		// those of the first piece, avc3 and hev1 let the in-band ones apply
+		args = append(args, "-tag:v", codec.Tag, "-movflags", "+faststart")
+	}
+	args = append(args, outputPath)
+
+	return runFFmpeg("splice trim segments", args...)
+}
+
+// smartCutEncodeArgs returns the encoder options that reproduce the source's
+// profile, level and pixel format, and false when that can not be guaranteed.
+func smartCutEncodeArgs(codec smartCutCodec, probe *videoProbe, pixelFormat string, encoding EncodingSettings) ([]string, bool) {
+	profile, level := probe.codecProfile()
+	encoderProfile, ok := codec.Profiles[profile]
+	if !ok || level <= 0 || pixelFormat == "" {
+		return nil, false
+	}
+
+	args := []string{"-c:v", codec.Encoder, "-crf", strconv.Itoa(encoding.CRF)}
+	if encoding.Preset != "" {
+		args = append(args, "-preset", encoding.Preset)
+	}
+	args = append(args, "-profile:v", encoderProfile)
+	args = append(args, codec.Level(level)...)
+	return append(args, "-pix_fmt", pixelFormat), true
+}
+
+// segmentArgs cuts one piece of a smart trim to MPEG-TS. Copied packets are turned
+// into Annex B with the parameter sets in front of every keyframe, and the encoders
+// repeat them on every keyframe by themselves when the container has no global
+// header to hold them.
+func segmentArgs(videoPath, segmentPath string, segment trimSegment, codec smartCutCodec, encodeArgs []string) []string {
+	if segment.Copy {
+		return []string{
+			"-ss", formatSeconds(segment.Start + keyframeSeekOffset), "-i", videoPath,
+			"-t", formatSeconds(segment.End - segment.Start),
+			"-map", "0:v:0", "-c", "copy", "-bsf:v", codec.AnnexB, "-f", "mpegts", segmentPath,
+		}
+	}
+
+	args := []string{
+		"-ss", formatSeconds(segment.Start), "-i", videoPath,
+		"-t", formatSeconds(segment.End - segment.Start),
+		"-map", "0:v:0",
+	}
+	args = append(args, encodeArgs...)
+	return append(args, "-f", "mpegts", segmentPath)
+}
+
+func formatSeconds(seconds float64) string {
+	return strconv.FormatFloat(seconds, 'f', 6, 64)
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const smartCutPackets = `{"packets":[
+	{"pts_time":"0.000000","flags":"K__"},{"pts_time":"0.033367","flags":"___"},
+	{"pts_time":"2.002000","flags":"K__"},{"pts_time":"4.004000","flags":"K__"},
+	{"pts_time":"6.006000","flags":"K__"},{"pts_time":"8.008000","flags":"K__"}
+]}`
+
+// smartCutOutput answers ffprobe with the keyframe packets when they are asked for
+// and with probe otherwise.
+func smartCutOutput(probe string) func(command string, args []string) string {
+	return func(command string, args []string) string {
+		if command == "ffprobe" && strings.Contains(strings.Join(args, " "), "packet=") {
+			return smartCutPackets
+		}
+		return probe
+	}
+}
+
+func TestPlanSmartCut(t *testing.T) {
+	keyframes := []float64{0, 2.002, 4.004, 6.006, 8.008}
+
+	assert.Equal(t, []trimSegment{
+		{Start: 1.5, End: 2.002},
+		{Start: 2.002, End: 6.006, Copy: true},
+		{Start: 6.006, End: 7.25},
+	}, planSmartCut(keyframes, 1.5, 7.25))
+
+	// cuts on keyframes need no re-encoding at that end
+	assert.Equal(t, []trimSegment{
+		{Start: 2.002, End: 6.006, Copy: true},
+		{Start: 6.006, End: 7},
+	}, planSmartCut(keyframes, 2.0025, 7))
+	assert.Equal(t, []trimSegment{
+		{Start: 0, End: 8.008, Copy: true},
+	}, planSmartCut(keyframes, 0, 8.008))
+
+	// within a single group of pictures there is nothing to copy
+	assert.Nil(t, planSmartCut(keyframes, 2.5, 5))
+	assert.Nil(t, planSmartCut(keyframes, 8.5, 9))
+}
+
+func TestProbeKeyframes(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return smartCutPackets
+	})
+
+	keyframes, err := probeKeyframes("input.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, []float64{0, 2.002, 4.004, 6.006, 8.008}, keyframes)
+}
+
+func TestTrimVideo_Smart(t *testing.T) {
+	runs := fakeCommands(t, smartCutOutput(
+		`{"streams":[{"codec_type":"video","codec_name":"h264","pix_fmt":"yuv420p","profile":"High","level":40},{"codec_type":"audio","codec_name":"aac"}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"10.0"}}`))
+
+	err := TrimVideo("input.mp4", "output.mp4", 1.5, 7.25, TrimOptions{Mode: TRIM_MODE_SMART})
+	assert.NoError(t, err)
+	assert.Len(t, *runs, 4)
+
+	head, middle, tail, splice := (*runs)[0], (*runs)[1], (*runs)[2], (*runs)[3]
+	assert.Equal(t, []string{"-ss", "1.500000", "-i", "input.mp4", "-t", "0.502000"}, head[:6])
+	assert.Contains(t, strings.Join(head, " "), "-c:v libx264 -crf 18 -preset medium -profile:v high -level:v 4.0 -pix_fmt yuv420p -f mpegts")
+	assert.Equal(t, []string{"-ss", "2.002500", "-i", "input.mp4", "-t", "4.004000", "-map", "0:v:0", "-c", "copy", "-bsf:v", "h264_mp4toannexb", "-f", "mpegts"}, middle[:14])
+	assert.Equal(t, []string{"-ss", "6.006000", "-i", "input.mp4", "-t", "1.244000"}, tail[:6])
+	for _, segment := range [][]string{head, middle, tail} {
+		assert.True(t, strings.HasSuffix(segment[len(segment)-1], ".ts"), segment[len(segment)-1])
+	}
+	assert.Contains(t, strings.Join(splice, " "), "-ss 1.500000 -t 5.750000 -i input.mp4 -map 0:v:0 -map 1:a:0? -c:v copy -c:a aac -tag:v avc3")
+	assert.Equal(t, "output.mp4", splice[len(splice)-1])
+
+	// the pieces are cut in a directory of their own, which is removed afterwards
+	entries, _ := os.ReadDir(STAGING_DIR)
+	for _, entry := range entries {
+		assert.False(t, strings.HasPrefix(entry.Name(), "trim-"), entry.Name())
+	}
+}
+
+func TestTrimVideo_SmartFallsBackToAccurate(t *testing.T) {
+	runs := fakeCommands(t, smartCutOutput(
+		`{"streams":[{"codec_type":"video","codec_name":"vp9","pix_fmt":"yuv420p"}],"format":{"format_name":"matroska,webm","duration":"10.0"}}`))
+
+	err := TrimVideo("input.webm", "output.mp4", 1.5, 7.25, TrimOptions{Mode: TRIM_MODE_SMART})
+	assert.NoError(t, err)
+	assert.Len(t, *runs, 1)
+	assert.Contains(t, strings.Join((*runs)[0], " "), "-ss 1.500 -i input.webm -t 5.750 -c:v libx264")
+}
+
+func TestTrimVideo_SmartFallsBackOnUnmatchedProfile(t *testing.T) {
+	for _, stream := range []string{
+		`"codec_name":"h264","pix_fmt":"yuv444p","profile":"High 4:4:4 Predictive","level":40`,
+		`"codec_name":"h264","pix_fmt":"yuv420p","profile":"High","level":-99`,
+		`"codec_name":"hevc","pix_fmt":"yuv420p10le","profile":"Main 10","level":120`,
+	} {
+		runs := fakeCommands(t, smartCutOutput(
+			`{"streams":[{"codec_type":"video",`+stream+`}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"10.0"}}`))
+
+		err := TrimVideo("input.mp4", "output.mp4", 1.5, 7.25, TrimOptions{Mode: TRIM_MODE_SMART})
+		assert.NoError(t, err)
+		if assert.Len(t, *runs, 1, stream) {
+			assert.Contains(t, strings.Join((*runs)[0], " "), "-ss 1.500 -i input.mp4 -t 5.750 -c:v libx264")
+		}
+	}
+}
+
+func TestSmartCutEncodeArgs_HEVCLevel(t *testing.T) {
+	probe := &videoProbe{}
+	if assert.NoError(t, json.Unmarshal([]byte(`{"streams":[{"codec_type":"video","codec_name":"hevc","profile":"Main","level":93}]}`), probe)) {
+		args, ok := smartCutEncodeArgs(smartCutCodecs["hevc"], probe, "yuv420p", TRIM_ENCODING)
+		assert.True(t, ok)
+		assert.Contains(t, strings.Join(args, " "), "-c:v libx265 -crf 18 -preset medium -profile:v main -x265-params level-idc=3.1 -pix_fmt yuv420p")
+	}
+}
@@ -0,0 +1,142 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// SNIFF_LENGTH is how much of the start of a file is read to recognise its container.
+const SNIFF_LENGTH = 4096
+
+// ALLOWED_VIDEO_CONTAINERS lists the containers uploads may use, as named by
+// SniffContainer.
+var ALLOWED_VIDEO_CONTAINERS = []string{"mp4", "mov", "mkv", "webm"}
+
+// containerFormats maps a sniffed container to the ffprobe format family it must be
+// reported as.
+var containerFormats = map[string]string{
+	"mp4":  "mp4",
+	"mov":  "mov",
+	"mkv":  "matroska",
+	"webm": "webm",
+}
+
+// InvalidVideoError explains why a file was refused as a video.
+type InvalidVideoError struct {
+	Reason string
+}
+
+func (e *InvalidVideoError) Error() string {
+	return e.Reason
+}
+
+// SniffContainer names the container of a file from its first bytes, or returns an
+// empty string when the signature is not recognised.
+func SniffContainer(header []byte) string {
+	switch {
+	case len(header) >= 12 && bytes.Equal(header[4:8], []byte("ftyp")):
+		brand := string(header[8:12])
+		switch {
+		case brand == "qt  ":
+			return "mov"
+		case strings.HasPrefix(brand, "3g"):
+			return "3gp"
+		case brand == "M4A " || brand == "M4B ":
+			return "m4a"
+		case brand == "heic" || brand == "heix" || brand == "mif1" || brand == "msf1":
+			return "heif"
+		default:
+			return "mp4"
+		}
+	case len(header) >= 8 && isQuickTimeAtom(header[4:8]):
+		// old QuickTime files start straight with an atom instead of ftyp
+		return "mov"
+	case bytes.HasPrefix(header, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if bytes.Contains(header[:min(len(header), 64)], []byte("webm")) {
+			return "webm"
+		}
+		return "mkv"
+	case len(header) >= 12 && bytes.HasPrefix(header, []byte("RIFF")) && bytes.Equal(header[8:12], []byte("AVI ")):
+		return "avi"
+	case bytes.HasPrefix(header, []byte("FLV")):
+		return "flv"
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return "ogg"
+	case bytes.HasPrefix(header, []byte{0x30, 0x26, 0xB2, 0x75}):
+		return "asf"
+	case bytes.HasPrefix(header, []byte{0x00, 0x00, 0x01, 0xBA}):
+		return "mpeg"
+	case len(header) > 188 && header[0] == 0x47 && header[188] == 0x47:
+		return "mpegts"
+	}
+	return ""
+}
+
+// CheckContainer refuses files whose signature is not one of the allowed containers.
+func CheckContainer(header []byte) (string, error) {
+	container := SniffContainer(header)
+	if container == "" {
+		return "", &InvalidVideoError{fmt.Sprintf(
+			"file is not a recognised video container, allowed containers are %s", allowedContainers(),
+		)}
+	}
+	for _, allowed := range ALLOWED_VIDEO_CONTAINERS {
+		if container == allowed {
+			return container, nil
+		}
+	}
+	return "", &InvalidVideoError{fmt.Sprintf(
+		"container %s is not allowed, allowed containers are %s", container, allowedContainers(),
+	)}
+}
+
+// CheckFileContainer reads the start of a file and checks its container.
+func CheckFileContainer(filePath string) (string, error) {
+	header, err := readFileHeader(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return CheckContainer(header)
+}
+
+// sniffFile names the container of a file without checking it against the allowlist.
+func sniffFile(filePath string) (string, error) {
+	header, err := readFileHeader(filePath)
+	if err != nil {
+		return "", err
+	}
+
+	return SniffContainer(header), nil
+}
+
+func readFileHeader(filePath string) ([]byte, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	header := make([]byte, SNIFF_LENGTH)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, err
+	}
+
+	return header[:n], nil
+}
+
+func isQuickTimeAtom(atom []byte) bool {
+	switch string(atom) {
+	case "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+func allowedContainers() string {
+	return strings.Join(ALLOWED_VIDEO_CONTAINERS, ", ")
+}
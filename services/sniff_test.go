@@ -0,0 +1,47 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSniffContainer(t *testing.T) {
+	tsHeader := make([]byte, 189)
+	tsHeader[0], tsHeader[188] = 0x47, 0x47
+
+	tests := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"mp4", []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00"), "mp4"},
+		{"mov", []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00"), "mov"},
+		{"old quicktime", []byte("\x00\x00\x00\x08wide\x00\x00\x00\x00"), "mov"},
+		{"3gp", []byte("\x00\x00\x00\x14ftyp3gp5\x00\x00\x02\x00"), "3gp"},
+		{"webm", []byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"), "webm"},
+		{"mkv", []byte("\x1a\x45\xdf\xa3\xa3\x42\x86\x81\x01\x42\x82\x88matroska"), "mkv"},
+		{"avi", []byte("RIFF\x00\x00\x00\x00AVI LIST"), "avi"},
+		{"mpegts", tsHeader, "mpegts"},
+		{"unknown", []byte("%PDF-1.7"), ""},
+		{"empty", []byte{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, SniffContainer(tt.header))
+		})
+	}
+}
+
+func TestCheckContainer(t *testing.T) {
+	container, err := CheckContainer([]byte("\x1a\x45\xdf\xa3\x9f\x42\x86\x81\x01\x42\x82\x84webm"))
+	assert.NoError(t, err)
+	assert.Equal(t, "webm", container)
+
+	_, err = CheckContainer([]byte("RIFF\x00\x00\x00\x00AVI LIST"))
+	assert.EqualError(t, err, "container avi is not allowed, allowed containers are mp4, mov, mkv, webm")
+
+	_, err = CheckContainer([]byte("%PDF-1.7"))
+	assert.EqualError(t, err, "file is not a recognised video container, allowed containers are mp4, mov, mkv, webm")
+}
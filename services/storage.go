@@ -0,0 +1,64 @@
+package services
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// containerExtensions is the extension a stored file gets for its sniffed container.
+var containerExtensions = map[string]string{
+	"mp4":  ".mp4",
+	"mov":  ".mov",
+	"mkv":  ".mkv",
+	"webm": ".webm",
+}
+
+// NewStoragePath returns a path in the store for a new video file. The name is a
+// random id, never anything the client sent, and the file sits two directories deep
+// under the first characters of that id so no single directory grows too large.
+func NewStoragePath(ext string) string {
+	id := uuid.NewString()
+	return filepath.Join(UPLOAD_DIR, id[0:2], id[2:4], id+ext)
+}
+
+// ContainerExtension returns the extension for files of a sniffed container.
+func ContainerExtension(container string) (string, error) {
+	ext, ok := containerExtensions[container]
+	if !ok {
+		return "", fmt.Errorf("no file extension for container %q", container)
+	}
+	return ext, nil
+}
+
+// StorageKey returns the path of a stored file relative to UPLOAD_DIR, with forward
+// slashes, as it is handed out in signed urls.
+func StorageKey(filePath string) (string, error) {
+	key, err := filepath.Rel(UPLOAD_DIR, filePath)
+	if err != nil {
+		return "", err
+	}
+	key = filepath.ToSlash(key)
+	if !ValidStorageKey(key) {
+		return "", fmt.Errorf("%s is not inside %s", filePath, UPLOAD_DIR)
+	}
+	return key, nil
+}
+
+// ValidStorageKey reports whether key names a file inside UPLOAD_DIR, so a key taken
+// from a request can not reach anything outside the store. Keys starting with a dot
+// are refused too, which covers ".." as well as the staging and tus directories.
+func ValidStorageKey(key string) bool {
+	if key == "" || strings.Contains(key, `\`) || path.IsAbs(key) || path.Clean(key) != key {
+		return false
+	}
+	return !strings.HasPrefix(key, ".")
+}
+
+// StoragePath turns a storage key back into a path on disk.
+func StoragePath(key string) string {
+	return filepath.Join(UPLOAD_DIR, filepath.FromSlash(key))
+}
@@ -0,0 +1,44 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewStoragePath(t *testing.T) {
+	first := NewStoragePath(".webm")
+	second := NewStoragePath(".webm")
+	assert.NotEqual(t, first, second)
+
+	key, err := StorageKey(first)
+	assert.NoError(t, err)
+	parts := strings.Split(key, "/")
+	assert.Len(t, parts, 3)
+	assert.True(t, strings.HasPrefix(parts[2], parts[0]+parts[1]))
+	assert.Equal(t, ".webm", filepath.Ext(key))
+	assert.Equal(t, first, StoragePath(key))
+}
+
+func TestStorageKey(t *testing.T) {
+	key, err := StorageKey(filepath.Join(UPLOAD_DIR, "1700000000000-old.mp4"))
+	assert.NoError(t, err)
+	assert.Equal(t, "1700000000000-old.mp4", key)
+
+	_, err = StorageKey("elsewhere/test.mp4")
+	assert.Error(t, err)
+
+	_, err = StorageKey(filepath.Join(STAGING_DIR, "upload-1"))
+	assert.Error(t, err)
+}
+
+func TestContainerExtension(t *testing.T) {
+	ext, err := ContainerExtension("mkv")
+	assert.NoError(t, err)
+	assert.Equal(t, ".mkv", ext)
+
+	_, err = ContainerExtension("")
+	assert.Error(t, err)
+}
@@ -0,0 +1,195 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	TUS_VERSION    = "1.0.0"
+	TUS_EXTENSIONS = "creation"
+	// TUS_UPLOAD_DIR lives inside UPLOAD_DIR so a completed upload can be renamed into
+	// the store instead of copied.
+	TUS_UPLOAD_DIR = UPLOAD_DIR + "/.tus"
+)
+
+var (
+	ErrUploadNotFound       = errors.New("upload not found")
+	ErrUploadOffsetMismatch = errors.New("upload offset does not match")
+)
+
+// TusUpload is a partial upload. Offset is not persisted, it is the size of the data
+// file so bytes written before a dropped connection still count.
+type TusUpload struct {
+	ID        string            `json:"id"`
+	Length    int64             `json:"length"`
+	Offset    int64             `json:"-"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt time.Time         `json:"created_at"`
+}
+
+func (u *TusUpload) Complete() bool {
+	return u.Offset == u.Length
+}
+
+// TusStore keeps partial uploads on disk as a data file and a json info file per
+// upload.
+type TusStore struct {
+	dir   string
+	locks sync.Map
+}
+
+func NewTusStore(dir string) *TusStore {
+	return &TusStore{dir: dir}
+}
+
+func (s *TusStore) Create(length int64, metadata map[string]string) (*TusUpload, error) {
+	if err := os.MkdirAll(s.dir, os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
+		return nil, fmt.Errorf("failed to create directory")
+	}
+
+	upload := &TusUpload{
+		ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Length:    length,
+		Metadata:  metadata,
+		CreatedAt: time.Now(),
+	}
+
+	info, err := json.Marshal(upload)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(s.infoPath(upload.ID), info, 0o644); err != nil {
+		log.Printf("[service] failed to save upload info: %s", err.Error())
+		return nil, fmt.Errorf("failed to save upload info")
+	}
+	if err := os.WriteFile(s.DataPath(upload.ID), nil, 0o644); err != nil {
+		os.Remove(s.infoPath(upload.ID))
+		log.Printf("[service] failed to create upload file: %s", err.Error())
+		return nil, fmt.Errorf("failed to create upload file")
+	}
+
+	return upload, nil
+}
+
+func (s *TusStore) Get(id string) (*TusUpload, error) {
+	if _, err := uuid.Parse(id); err != nil {
+		return nil, ErrUploadNotFound
+	}
+
+	info, err := os.ReadFile(s.infoPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+
+	var upload TusUpload
+	if err := json.Unmarshal(info, &upload); err != nil {
+		return nil, err
+	}
+
+	fileInfo, err := os.Stat(s.DataPath(id))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, ErrUploadNotFound
+		}
+		return nil, err
+	}
+	upload.Offset = fileInfo.Size()
+
+	return &upload, nil
+}
+
+// WriteChunk appends the body to the upload when offset matches what has been
+// received so far. Whatever arrived before a read error is kept.
+func (s *TusStore) WriteChunk(id string, offset int64, body io.Reader) (*TusUpload, error) {
+	upload, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != upload.Offset {
+		return upload, ErrUploadOffsetMismatch
+	}
+
+	dataFile, err := os.OpenFile(s.DataPath(id), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer dataFile.Close()
+
+	written, err := io.Copy(dataFile, io.LimitReader(body, upload.Length-upload.Offset))
+	upload.Offset += written
+	if err != nil {
+		log.Printf("[service] upload %s interrupted at offset %d: %s", id, upload.Offset, err.Error())
+		return upload, err
+	}
+
+	return upload, nil
+}
+
+// Lock reserves the upload for a single request at a time. It reports false when
+// another request holds it.
+func (s *TusStore) Lock(id string) (func(), bool) {
+	lock, _ := s.locks.LoadOrStore(id, new(sync.Mutex))
+	mutex := lock.(*sync.Mutex)
+	if !mutex.TryLock() {
+		return nil, false
+	}
+	return mutex.Unlock, true
+}
+
+func (s *TusStore) Remove(id string) error {
+	if err := os.Remove(s.DataPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Remove(s.infoPath(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	s.locks.Delete(id)
+	return nil
+}
+
+func (s *TusStore) DataPath(id string) string {
+	return filepath.Join(s.dir, id+".bin")
+}
+
+func (s *TusStore) infoPath(id string) string {
+	return filepath.Join(s.dir, id+".info")
+}
+
+// ParseTusMetadata decodes an Upload-Metadata header: comma separated pairs of a key
+// and an optional base64 encoded value.
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("malformed upload metadata %q", pair)
+		}
+		value, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil {
+			return nil, fmt.Errorf("malformed upload metadata value for %q", key)
+		}
+		metadata[key] = string(value)
+	}
+
+	return metadata, nil
+}
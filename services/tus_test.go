@@ -0,0 +1,76 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTusMetadata(t *testing.T) {
+	metadata, err := ParseTusMetadata("filename Y2xpcC5tcDQ=, is_confidential")
+
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"filename": "clip.mp4", "is_confidential": ""}, metadata)
+
+	_, err = ParseTusMetadata("filename not-base64!")
+	assert.Error(t, err)
+}
+
+func TestTusStore_WriteChunks(t *testing.T) {
+	store := NewTusStore(t.TempDir())
+
+	upload, err := store.Create(10, map[string]string{"filename": "clip.mp4"})
+	assert.NoError(t, err)
+
+	upload, err = store.WriteChunk(upload.ID, 0, bytes.NewReader([]byte("hello")))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5), upload.Offset)
+	assert.False(t, upload.Complete())
+
+	_, err = store.WriteChunk(upload.ID, 0, bytes.NewReader([]byte("again")))
+	assert.ErrorIs(t, err, ErrUploadOffsetMismatch)
+
+	upload, err = store.WriteChunk(upload.ID, 5, bytes.NewReader([]byte("world and more")))
+	assert.NoError(t, err)
+	assert.True(t, upload.Complete())
+
+	data, _ := os.ReadFile(store.DataPath(upload.ID))
+	assert.Equal(t, "helloworld", string(data))
+
+	assert.NoError(t, store.Remove(upload.ID))
+	_, err = store.Get(upload.ID)
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+type failingReader struct{ data []byte }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestTusStore_KeepsBytesOfInterruptedChunk(t *testing.T) {
+	store := NewTusStore(t.TempDir())
+	upload, _ := store.Create(10, map[string]string{})
+
+	_, err := store.WriteChunk(upload.ID, 0, &failingReader{data: []byte("hel")})
+	assert.Error(t, err)
+
+	upload, err = store.Get(upload.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), upload.Offset)
+}
+
+func TestTusStore_GetRejectsInvalidID(t *testing.T) {
+	store := NewTusStore(t.TempDir())
+
+	_, err := store.Get("../../etc/passwd")
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
@@ -2,15 +2,20 @@ package services
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
-	"mime/multipart"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strconv"
 	"strings"
-	"time"
+
+	"github.com/3ssalunke/videoverse/db"
 )
 
 const (
@@ -18,11 +23,17 @@ const (
 	MIN_VIDEO_DURATION_SECONDS = 5
 	MAX_VIDEO_DURATION_SECONDS = 50
 	UPLOAD_DIR                 = "video_store"
+	// STAGING_DIR lives inside UPLOAD_DIR so moving a staged file into the store is a
+	// rename on the same filesystem.
+	STAGING_DIR = UPLOAD_DIR + "/.staging"
 )
 
+var ErrVideoTooLarge = fmt.Errorf("file size exceeds the maximum allowed size of %d MB", MAX_VIDEO_SIZE_MB)
+
 type VideoMeta struct {
 	FileSize     int64
 	FileDuration float64
+	Media        db.VideoMedia
 }
 
 type UploadedVideo struct {
@@ -30,57 +41,121 @@ type UploadedVideo struct {
 	FilePath string
 }
 
-var UploadVideo = func(file multipart.File, fileHeader *multipart.FileHeader) (*UploadedVideo, error) {
-	if err := os.MkdirAll(UPLOAD_DIR, os.ModePerm); err != nil {
+// StagedVideo is an upload written to disk but not yet validated or moved into the
+// store. Filename is the name the client gave it, kept only as metadata, SHA256 the
+// hex digest of its bytes and Container the one sniffed from its signature.
+type StagedVideo struct {
+	Path      string
+	Filename  string
+	Size      int64
+	SHA256    string
+	Container string
+}
+
+// StageVideo writes the upload to a staging file in a single pass, hashing it on the
+// way. Files whose signature is not an allowed container are refused with an
+// InvalidVideoError before anything is written. It stops reading as soon as the
+// upload goes over the size limit and returns ErrVideoTooLarge.
+var StageVideo = func(file io.Reader, filename string) (*StagedVideo, error) {
+	if err := os.MkdirAll(STAGING_DIR, os.ModePerm); err != nil {
 		log.Printf("[service] failed to create directory: %s", err.Error())
 		return nil, fmt.Errorf("failed to create directory")
 	}
 
-	filename := fmt.Sprintf("%d-%s", time.Now().UnixMilli(), fileHeader.Filename)
-	savePath := filepath.Join(UPLOAD_DIR, filename)
-	outputFile, err := os.Create(savePath)
+	// look at the signature before writing anything, so a file that is not a video is
+	// refused without reading the rest of it
+	header := make([]byte, SNIFF_LENGTH)
+	n, err := io.ReadFull(file, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		log.Printf("[service] failed to read upload: %s", err.Error())
+		return nil, fmt.Errorf("failed to read upload")
+	}
+	header = header[:n]
+	container, err := CheckContainer(header)
 	if err != nil {
-		log.Printf("[service] failed to save video file: %s", err.Error())
-		return nil, fmt.Errorf("failed to save video file")
+		log.Printf("[service] refused %s: %s", filename, err.Error())
+		return nil, err
+	}
+
+	stagedFile, err := os.CreateTemp(STAGING_DIR, "upload-*")
+	if err != nil {
+		log.Printf("[service] failed to create staging file: %s", err.Error())
+		return nil, fmt.Errorf("failed to create staging file")
+	}
+	defer stagedFile.Close()
+
+	hash := sha256.New()
+	maxVideoSizeBytes := int64(MAX_VIDEO_SIZE_MB * 1024 * 1024)
+	size, err := io.Copy(io.MultiWriter(stagedFile, hash), io.LimitReader(io.MultiReader(bytes.NewReader(header), file), maxVideoSizeBytes+1))
+	if err != nil {
+		os.Remove(stagedFile.Name())
+		log.Printf("[service] failed to stage video: %s", err.Error())
+		return nil, fmt.Errorf("failed to stage video")
+	}
+	if size > maxVideoSizeBytes {
+		os.Remove(stagedFile.Name())
+		log.Printf("[service] file size exceeds the maximum allowed size of %d MB", MAX_VIDEO_SIZE_MB)
+		return nil, ErrVideoTooLarge
 	}
-	defer outputFile.Close()
 
-	if _, err := file.Seek(0, 0); err != nil {
-		log.Printf("[service] failed to reset file pointer: %s", err.Error())
-		return nil, fmt.Errorf("failed to reset file pointer")
+	return &StagedVideo{
+		Path:      stagedFile.Name(),
+		Filename:  filepath.Base(filename),
+		Size:      size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Container: container,
+	}, nil
+}
+
+// HashFile returns the hex SHA-256 digest of a file that was not hashed while it was
+// written.
+func HashFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
 	}
-	if _, err := outputFile.ReadFrom(file); err != nil {
-		log.Printf("[service] failed to save video: %s", err.Error())
-		return nil, fmt.Errorf("failed to save video")
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
 	}
 
-	return &UploadedVideo{Filename: filename, FilePath: savePath}, nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-var ValidateVideo = func(file multipart.File, fileHeader *multipart.FileHeader) (*VideoMeta, error) {
+// ValidateVideo checks the staged file where it is, without copying it: its signature,
+// that ffprobe agrees on the container and finds a video stream, and its duration.
+var ValidateVideo = func(video *StagedVideo) (*VideoMeta, error) {
 	maxVideoSizeBytes := MAX_VIDEO_SIZE_MB * 1024 * 1024
-	if fileHeader.Size > int64(maxVideoSizeBytes) {
+	if video.Size > int64(maxVideoSizeBytes) {
 		log.Printf("[service] file size exceeds the maximum allowed size of %d MB", MAX_VIDEO_SIZE_MB)
-		return nil, fmt.Errorf("file size exceeds the maximum allowed size of %d MB", MAX_VIDEO_SIZE_MB)
+		return nil, ErrVideoTooLarge
 	}
 
-	tempFile, err := os.CreateTemp("", "upload-*.mp4")
+	container, err := CheckFileContainer(video.Path)
 	if err != nil {
-		log.Printf("[service] failed to create temporary file: %s", err.Error())
-		return nil, fmt.Errorf("failed to create temporary file")
+		log.Printf("[service] refused %s: %s", video.Filename, err.Error())
+		return nil, err
 	}
-	defer os.Remove(tempFile.Name())
+	video.Container = container
 
-	if _, err := file.Seek(0, 0); err != nil {
-		log.Printf("[service] failed to reset file pointer: %s", err.Error())
-		return nil, fmt.Errorf("failed to reset file pointer")
+	probe, err := probeVideo(video.Path)
+	if err != nil {
+		log.Printf("[service] failed to probe video: %s", err.Error())
+		return nil, &InvalidVideoError{"file could not be read as a video"}
+	}
+
+	if !strings.Contains(probe.Format.FormatName, containerFormats[container]) {
+		log.Printf("[service] %s looks like %s but ffprobe reads it as %s", video.Filename, container, probe.Format.FormatName)
+		return nil, &InvalidVideoError{fmt.Sprintf("file looks like %s but its contents are %s", container, probe.Format.FormatName)}
 	}
-	if _, err := tempFile.ReadFrom(file); err != nil {
-		log.Printf("[service] failed to copy file to temp file: %s", err.Error())
-		return nil, fmt.Errorf("failed to copy file to temp file")
+	if !probe.hasVideoStream() {
+		log.Printf("[service] %s has no video stream", video.Filename)
+		return nil, &InvalidVideoError{"file has no video stream"}
 	}
 
-	duration, err := getVideoDuration(tempFile.Name())
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
 	if err != nil {
 		log.Printf("[service] failed to get video duration: %s", err.Error())
 		return nil, fmt.Errorf("failed to get video duration")
@@ -91,69 +166,327 @@ var ValidateVideo = func(file multipart.File, fileHeader *multipart.FileHeader)
 		return nil, fmt.Errorf("video duration must be between %d and %d seconds", MIN_VIDEO_DURATION_SECONDS, MAX_VIDEO_DURATION_SECONDS)
 	}
 
-	return &VideoMeta{FileSize: fileHeader.Size, FileDuration: duration}, nil
+	return &VideoMeta{FileSize: video.Size, FileDuration: duration, Media: probe.media(container)}, nil
+}
+
+// UploadVideo moves the staged file into the store with an atomic rename, so the
+// store never holds a partially written video. The stored name comes from
+// NewStoragePath and the sniffed container, not from the client's filename.
+var UploadVideo = func(video *StagedVideo) (*UploadedVideo, error) {
+	ext, err := ContainerExtension(video.Container)
+	if err != nil {
+		log.Printf("[service] failed to name stored video: %s", err.Error())
+		return nil, fmt.Errorf("failed to save video file")
+	}
+
+	savePath := NewStoragePath(ext)
+	if err := os.MkdirAll(filepath.Dir(savePath), os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
+		return nil, fmt.Errorf("failed to create directory")
+	}
+
+	if err := os.Rename(video.Path, savePath); err != nil {
+		log.Printf("[service] failed to save video file: %s", err.Error())
+		return nil, fmt.Errorf("failed to save video file")
+	}
+
+	return &UploadedVideo{Filename: filepath.Base(savePath), FilePath: savePath}, nil
+}
+
+// DiscardStagedVideo removes a staged file that was not moved into the store.
+func DiscardStagedVideo(video *StagedVideo) {
+	if video == nil {
+		return
+	}
+	if err := os.Remove(video.Path); err != nil && !os.IsNotExist(err) {
+		log.Printf("[service] failed to remove staged video: %s", err.Error())
+	}
 }
 
 var execCommand = exec.Command
 
-func getVideoDuration(filePath string) (float64, error) {
-	cmd := execCommand("ffprobe", "-v", "error", "-show_entries", "format=duration", "-of", "default=noprint_wrappers=1:nokey=1", filePath)
+func runFFmpeg(action string, args ...string) error {
+	cmd := execCommand("ffmpeg", args...)
 
 	var outBuf bytes.Buffer
-	cmd.Stdout = &outBuf
 	cmd.Stderr = &outBuf
 
 	if err := cmd.Run(); err != nil {
-		return 0, fmt.Errorf("error running ffprobe: %s %s", outBuf.String(), err.Error())
+		log.Printf("[service] failed to %s: %s %s", action, outBuf.String(), err.Error())
+		return err
+	}
+
+	return nil
+}
+
+type videoProbe struct {
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        int    `json:"width"`
+		Height       int    `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+		RFrameRate   string `json:"r_frame_rate"`
+		PixFmt       string `json:"pix_fmt"`
+		Profile      string `json:"profile"`
+		Level        int    `json:"level"`
+		SampleRate   string `json:"sample_rate"`
+		Channels     int    `json:"channels"`
+		Disposition  struct {
+			AttachedPic int `json:"attached_pic"`
+		} `json:"disposition"`
+		Tags struct {
+			Rotate string `json:"rotate"`
+		} `json:"tags"`
+		SideDataList []struct {
+			Rotation *float64 `json:"rotation"`
+		} `json:"side_data_list"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// hasVideoStream ignores cover art, which ffprobe also reports as a video stream.
+func (p *videoProbe) hasVideoStream() bool {
+	for _, stream := range p.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// codecProfile returns the profile name and level ffprobe reports for the first
+// video stream. The level is in the codec's own units, -99 when unknown.
+func (p *videoProbe) codecProfile() (string, int) {
+	for _, stream := range p.Streams {
+		if stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 {
+			return stream.Profile, stream.Level
+		}
+	}
+	return "", 0
+}
+
+// media picks the first video and audio streams out of the probe. Fields ffprobe
+// left out stay at their zero value.
+func (p *videoProbe) media(container string) db.VideoMedia {
+	media := db.VideoMedia{Container: container, StreamCount: len(p.Streams)}
+	media.BitRate, _ = strconv.ParseInt(p.Format.BitRate, 10, 64)
+
+	videoFound, audioFound := false, false
+	for _, stream := range p.Streams {
+		switch {
+		case stream.CodecType == "video" && stream.Disposition.AttachedPic == 0 && !videoFound:
+			videoFound = true
+			media.VideoCodec = stream.CodecName
+			media.Width, media.Height = stream.Width, stream.Height
+			media.PixelFormat = stream.PixFmt
+			media.FrameRate = parseFrameRate(stream.AvgFrameRate)
+			if media.FrameRate == 0 {
+				media.FrameRate = parseFrameRate(stream.RFrameRate)
+			}
+			// newer ffprobe reports a display matrix, counter-clockwise, older ones
+			// a rotate tag, clockwise
+			for _, sideData := range stream.SideDataList {
+				if sideData.Rotation != nil {
+					media.Rotation = normalizeRotation(-int(math.Round(*sideData.Rotation)))
+				}
+			}
+			if rotate, err := strconv.Atoi(stream.Tags.Rotate); err == nil && media.Rotation == 0 {
+				media.Rotation = normalizeRotation(rotate)
+			}
+		case stream.CodecType == "audio" && !audioFound:
+			audioFound = true
+			media.AudioCodec = stream.CodecName
+			media.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+			media.Channels = stream.Channels
+		}
 	}
 
-	duration, err := strconv.ParseFloat(strings.TrimSpace(outBuf.String()), 64)
+	return media
+}
+
+// parseFrameRate reads ffprobe's "30000/1001" style rates, rounded to 3 decimals.
+// Unknown rates come as "0/0" and give 0.
+func parseFrameRate(rate string) float64 {
+	num, den, found := strings.Cut(rate, "/")
+	numerator, err := strconv.ParseFloat(num, 64)
 	if err != nil {
-		return 0, fmt.Errorf("failed to parse duration: %w", err)
+		return 0
+	}
+	if !found {
+		return numerator
+	}
+	denominator, err := strconv.ParseFloat(den, 64)
+	if err != nil || denominator == 0 {
+		return 0
 	}
+	return math.Round(numerator/denominator*1000) / 1000
+}
 
-	return duration, err
+func normalizeRotation(degrees int) int {
+	return ((degrees % 360) + 360) % 360
 }
 
-var TrimVideo = func(videoPath, outputPath string, startTs, endTs, duration float64) error {
-	cmd := execCommand("ffmpeg",
-		"-i", videoPath,
-		"-ss", fmt.Sprintf("%.2f", startTs),
-		"-to", fmt.Sprintf("%.2f", endTs),
-		"-c", "copy", outputPath)
+func probeVideo(filePath string) (*videoProbe, error) {
+	cmd := execCommand("ffprobe", "-v", "error",
+		"-show_entries", "format=format_name,duration,bit_rate"+
+			":stream=codec_type,codec_name,width,height,avg_frame_rate,r_frame_rate,pix_fmt,profile,level,sample_rate,channels"+
+			":stream_disposition=attached_pic:stream_tags=rotate:stream_side_data=rotation",
+		"-of", "json", filePath)
 
-	var outBuf bytes.Buffer
-	cmd.Stderr = &outBuf
+	var outBuf, errBuf bytes.Buffer
+	cmd.Stdout = &outBuf
+	cmd.Stderr = &errBuf
 
 	if err := cmd.Run(); err != nil {
-		log.Printf("[service] failed to trim video: %s %s", outBuf.String(), err.Error())
+		return nil, fmt.Errorf("error running ffprobe: %s %s", errBuf.String(), err.Error())
+	}
+
+	var probe videoProbe
+	if err := json.Unmarshal(outBuf.Bytes(), &probe); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+
+	return &probe, nil
+}
+
+// ProbeVideo reads the duration and media details of a file the server wrote itself,
+// such as the output of a trim or merge, which does not go through ValidateVideo.
+// FileSize is left to the caller, which stats the file anyway.
+var ProbeVideo = func(filePath string) (*VideoMeta, error) {
+	container, err := sniffFile(filePath)
+	if err != nil {
+		log.Printf("[service] failed to read video: %s", err.Error())
+		return nil, fmt.Errorf("failed to read video")
+	}
+
+	probe, err := probeVideo(filePath)
+	if err != nil {
+		log.Printf("[service] failed to probe video: %s", err.Error())
+		return nil, fmt.Errorf("failed to probe video")
+	}
+
+	duration, err := strconv.ParseFloat(probe.Format.Duration, 64)
+	if err != nil {
+		log.Printf("[service] failed to get video duration: %s", err.Error())
+		return nil, fmt.Errorf("failed to get video duration")
+	}
+
+	return &VideoMeta{FileDuration: duration, Media: probe.media(container)}, nil
+}
+
+// TrimVideo cuts startTs to endTs out of a video. TRIM_MODE_FAST copies the streams,
+// so the cut snaps to the keyframes around it. TRIM_MODE_ACCURATE re-encodes with
+// TRIM_ENCODING, or the codec and CRF of options, and cuts on the exact frames, at
+// the cost of time and quality. TRIM_MODE_SMART cuts on the exact frames too but
+// only re-encodes the ends, see smartTrimVideo.
+var TrimVideo = func(videoPath, outputPath string, startTs, endTs float64, options TrimOptions) error {
+	encoding, err := options.encoding()
+	if err != nil {
+		return err
+	}
+	if options.Mode == TRIM_MODE_FAST && (options.VideoCodec != "" || options.CRF != nil) {
+		return fmt.Errorf("fast trims copy the streams and take no codec or crf")
+	}
+	if options.Mode == TRIM_MODE_SMART && options.VideoCodec != "" {
+		return fmt.Errorf("smart trims keep the codec of the source")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
 		return err
 	}
 
-	return nil
+	switch options.Mode {
+	case TRIM_MODE_FAST:
+		return runFFmpeg("trim video",
+			"-i", videoPath,
+			"-ss", fmt.Sprintf("%.2f", startTs),
+			"-to", fmt.Sprintf("%.2f", endTs),
+			"-c", "copy", outputPath)
+	case TRIM_MODE_ACCURATE:
+		return accurateTrimVideo(videoPath, outputPath, startTs, endTs, encoding)
+	case TRIM_MODE_SMART:
+		return smartTrimVideo(videoPath, outputPath, startTs, endTs, encoding)
+	default:
+		return fmt.Errorf("unknown trim mode %q", options.Mode)
+	}
 }
 
-var MergeVideos = func(videoPaths []string, outputPath string) error {
-	videoPathsFilename := "videos.txt"
+// accurateTrimVideo seeks on the input, which decodes from the keyframe before startTs
+// and drops the frames up to it, so the output starts on the exact frame.
+func accurateTrimVideo(videoPath, outputPath string, startTs, endTs float64, encoding EncodingSettings) error {
+	args := append([]string{
+		"-ss", fmt.Sprintf("%.3f", startTs),
+		"-i", videoPath,
+		"-t", fmt.Sprintf("%.3f", endTs-startTs),
+	}, encoding.args()...)
+	args = append(args, outputPath)
+
+	return runFFmpeg("trim video", args...)
+}
+
+// MergeVideos joins videos end to end. When they share codecs, picture size, frame
+// rate and audio layout, and no other target was asked for, the streams are copied.
+// Otherwise every input is normalised to target, see normaliseMergeVideos.
+var MergeVideos = func(videoPaths []string, outputPath string, target MergeTarget) error {
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
+		return err
+	}
+
+	inputs, err := probeMergeInputs(videoPaths)
+	if err != nil {
+		return err
+	}
+
+	if mergeCompatible(inputs, target, filepath.Ext(outputPath)) {
+		return concatVideos(videoPaths, outputPath)
+	}
+
+	log.Printf("[service] merge inputs differ, normalising them")
+	return normaliseMergeVideos(inputs, outputPath, resolveMergeTarget(target, inputs))
+}
 
-	videoPathsFile, err := os.Create(videoPathsFilename)
+// concatVideos joins videos with the concat demuxer, copying their streams. Every
+// merge writes its own list, so merges running at once do not overwrite each other's.
+func concatVideos(videoPaths []string, outputPath string) error {
+	if err := os.MkdirAll(STAGING_DIR, os.ModePerm); err != nil {
+		log.Printf("[service] failed to create directory: %s", err.Error())
+		return err
+	}
+	videoPathsFile, err := os.CreateTemp(STAGING_DIR, "merge-*.txt")
 	if err != nil {
 		log.Printf("[service] failed to create a file for input video paths: %s", err.Error())
 		return err
 	}
+	videoPathsFilename := videoPathsFile.Name()
 	defer func() {
 		videoPathsFile.Close()
 		os.Remove(videoPathsFilename)
 	}()
 
 	for _, path := range videoPaths {
-		abspath := filepath.ToSlash(path)
-		_, err := videoPathsFile.WriteString(fmt.Sprintf("file %s\n", abspath))
+		// the demuxer resolves relative paths against the list, not the working directory
+		abspath, err := filepath.Abs(path)
+		if err != nil {
+			log.Printf("[service] failed to resolve video path: %s", err.Error())
+			return err
+		}
+		_, err = videoPathsFile.WriteString(fmt.Sprintf("file '%s'\n", strings.ReplaceAll(filepath.ToSlash(abspath), "'", `'\''`)))
 		if err != nil {
 			log.Printf("[service] failed to write to videoPathsFile file: %s", err.Error())
 			return err
 		}
 	}
+	if err := videoPathsFile.Close(); err != nil {
+		log.Printf("[service] failed to write to videoPathsFile file: %s", err.Error())
+		return err
+	}
 
 	args := []string{"-f", "concat", "-safe", "0", "-i", videoPathsFilename, "-c", "copy", outputPath}
 	cmd := execCommand("ffmpeg", args...)
@@ -1,102 +1,228 @@
 package services
 
 import (
-	"io"
-	"mime/multipart"
+	"bytes"
+	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/3ssalunke/videoverse/db"
 	"github.com/stretchr/testify/assert"
 )
 
-type MockFile struct {
-	data   []byte
-	cursor int64
-}
+// testVideoData starts with an mp4 ftyp box so it passes the container sniff.
+var testVideoData = []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomiso2test video data")
 
-func (m *MockFile) Read(p []byte) (n int, err error) {
-	if m.cursor >= int64(len(m.data)) {
-		return 0, io.EOF
+// fakeCommands makes execCommand run this test binary, which prints what output
+// returns for the command and its arguments, as ffprobe would. The arguments of
+// every ffmpeg run are recorded in order.
+func fakeCommands(t *testing.T, output func(command string, args []string) string) *[][]string {
+	var runs [][]string
+	execCommand = func(command string, args ...string) *exec.Cmd {
+		if command == "ffmpeg" {
+			runs = append(runs, args)
+		}
+		cmd := exec.Command(os.Args[0], "-test.run=TestHelperProcess", "--", output(command, args))
+		cmd.Env = append(os.Environ(), "GO_WANT_HELPER_PROCESS=1")
+		return cmd
 	}
-
-	n = copy(p, m.data[m.cursor:])
-	m.cursor += int64(n)
-	return n, nil
+	t.Cleanup(func() {
+		execCommand = exec.Command
+	})
+	return &runs
 }
 
-func (m *MockFile) Seek(offset int64, whence int) (int64, error) {
-	if whence == io.SeekStart {
-		m.cursor = offset
-	} else if whence == io.SeekCurrent {
-		m.cursor += offset
-	} else if whence == io.SeekEnd {
-		m.cursor = int64(len(m.data)) + offset
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("GO_WANT_HELPER_PROCESS") != "1" {
+		return
 	}
-
-	return m.cursor, nil
-}
-
-func (m *MockFile) Close() error {
-	return nil
+	fmt.Fprint(os.Stdout, os.Args[len(os.Args)-1])
+	os.Exit(0)
 }
 
-func (m *MockFile) ReadAt(p []byte, off int64) (n int, err error) {
-	return 0, nil
+func stageTestVideo(t *testing.T, data []byte) *StagedVideo {
+	stagedVideo, err := StageVideo(bytes.NewReader(data), "test.mp4")
+	assert.NoError(t, err)
+	t.Cleanup(func() {
+		DiscardStagedVideo(stagedVideo)
+	})
+	return stagedVideo
 }
 
 // Upload video
 func TestUploadVideo(t *testing.T) {
-	mockData := []byte("test video data")
-	mockFile := &MockFile{data: mockData}
-	fileHeader := &multipart.FileHeader{Filename: "test.mp4", Size: int64(len(mockData))}
+	stagedVideo, err := StageVideo(bytes.NewReader(testVideoData), "test.mp4")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(testVideoData)), stagedVideo.Size)
+	assert.Equal(t, "bd90079c520b0cee96e22246f01e5ae1d7c3f6d46bd7f96fccfebe7512d23017", stagedVideo.SHA256)
+	assert.True(t, strings.HasPrefix(stagedVideo.Path, STAGING_DIR))
+
+	assert.Equal(t, "mp4", stagedVideo.Container)
 
-	uploadedVideo, err := UploadVideo(mockFile, fileHeader)
+	uploadedVideo, err := UploadVideo(stagedVideo)
 	assert.NoError(t, err)
 	assert.NotNil(t, uploadedVideo)
-	assert.True(t, strings.HasPrefix(uploadedVideo.FilePath, UPLOAD_DIR))
+	assert.NotContains(t, uploadedVideo.FilePath, "test")
+	assert.Equal(t, ".mp4", filepath.Ext(uploadedVideo.FilePath))
+	key, err := StorageKey(uploadedVideo.FilePath)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(key, "/"), 3)
+
+	_, err = os.Stat(stagedVideo.Path)
+	assert.True(t, os.IsNotExist(err))
+	data, _ := os.ReadFile(uploadedVideo.FilePath)
+	assert.Equal(t, testVideoData, data)
 
 	os.Remove(uploadedVideo.FilePath)
+	os.Remove(filepath.Dir(uploadedVideo.FilePath))
+	os.Remove(filepath.Dir(filepath.Dir(uploadedVideo.FilePath)))
 }
 
 // Validate Video
-func TestValidateVideo_SizeExceeds(t *testing.T) {
+func TestStageVideo_SizeExceeds(t *testing.T) {
 	mockData := make([]byte, MAX_VIDEO_SIZE_MB*1024*1024+1)
-	mockFile := &MockFile{data: mockData}
-	fileHeader := &multipart.FileHeader{Filename: "large.mp4", Size: int64(len(mockData))}
+	copy(mockData, testVideoData)
+
+	stagedVideo, err := StageVideo(bytes.NewReader(mockData), "large.mp4")
+	assert.ErrorIs(t, err, ErrVideoTooLarge)
+	assert.Nil(t, stagedVideo)
+}
 
-	videoMeta, err := ValidateVideo(mockFile, fileHeader)
+func TestStageVideo_NotAVideo(t *testing.T) {
+	stagedVideo, err := StageVideo(strings.NewReader("%PDF-1.7 not a video"), "notes.mp4")
+
+	var invalidErr *InvalidVideoError
+	assert.ErrorAs(t, err, &invalidErr)
+	assert.Equal(t, "file is not a recognised video container, allowed containers are mp4, mov, mkv, webm", err.Error())
+	assert.Nil(t, stagedVideo)
+}
+
+func TestValidateVideo_SizeExceeds(t *testing.T) {
+	stagedVideo := &StagedVideo{Path: "large.mp4", Filename: "large.mp4", Size: MAX_VIDEO_SIZE_MB*1024*1024 + 1}
+
+	videoMeta, err := ValidateVideo(stagedVideo)
 	assert.Error(t, err)
 	assert.Nil(t, videoMeta)
 }
 
-func TestGetVideoDuration(t *testing.T) {
-	execCommand = func(command string, args ...string) *exec.Cmd {
-		return exec.Command("cmd", "/C", "echo 30.5")
-	}
+func TestProbeVideo(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"30.5"}}`
+	})
 
-	duration, err := getVideoDuration("test.mp4")
+	probe, err := probeVideo("test.mp4")
 	assert.NoError(t, err)
-	assert.Equal(t, 30.5, duration)
+	assert.Equal(t, "mov,mp4,m4a,3gp,3g2,mj2", probe.Format.FormatName)
+	assert.Equal(t, "30.5", probe.Format.Duration)
+	assert.True(t, probe.hasVideoStream())
+}
 
-	execCommand = exec.Command
+func TestValidateVideo_Success(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"video","codec_name":"h264"},{"codec_type":"audio","codec_name":"aac"}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"30.5"}}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
+
+	videoMeta, err := ValidateVideo(stagedVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, 30.5, videoMeta.FileDuration)
+	assert.Equal(t, stagedVideo.Size, videoMeta.FileSize)
 }
 
 func TestValidateVideo_InvalidDuration(t *testing.T) {
-	execCommand = func(command string, args ...string) *exec.Cmd {
-		return exec.Command("cmd", "/C", "echo 2.5")
-	}
-
-	mockData := []byte("test video data")
-	mockFile := &MockFile{data: mockData}
-	fileHeader := &multipart.FileHeader{Filename: "large.mp4", Size: int64(len(mockData))}
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"2.5"}}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
 
-	videoMeta, err := ValidateVideo(mockFile, fileHeader)
+	videoMeta, err := ValidateVideo(stagedVideo)
 	assert.Error(t, err)
 	assert.Nil(t, videoMeta)
+}
 
-	execCommand = exec.Command
+func TestValidateVideo_NoVideoStream(t *testing.T) {
+	// cover art is reported as a video stream but does not make a file a video
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"audio","codec_name":"aac"},{"codec_type":"video","codec_name":"mjpeg","disposition":{"attached_pic":1}}],"format":{"format_name":"mov,mp4,m4a,3gp,3g2,mj2","duration":"30.5"}}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
+
+	videoMeta, err := ValidateVideo(stagedVideo)
+	assert.EqualError(t, err, "file has no video stream")
+	assert.Nil(t, videoMeta)
+}
+
+func TestValidateVideo_ContainerMismatch(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"video","codec_name":"h264"}],"format":{"format_name":"mpegts","duration":"30.5"}}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
+
+	videoMeta, err := ValidateVideo(stagedVideo)
+	var invalidErr *InvalidVideoError
+	assert.ErrorAs(t, err, &invalidErr)
+	assert.Equal(t, "file looks like mp4 but its contents are mpegts", err.Error())
+	assert.Nil(t, videoMeta)
+}
+
+func TestValidateVideo_Media(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return `{
+		"streams": [
+			{"codec_type": "video", "codec_name": "hevc", "width": 3840, "height": 2160, "avg_frame_rate": "30000/1001", "r_frame_rate": "30/1", "pix_fmt": "yuv420p10le",
+			 "side_data_list": [{"side_data_type": "Display Matrix", "rotation": -90}]},
+			{"codec_type": "audio", "codec_name": "aac", "sample_rate": "48000", "channels": 2},
+			{"codec_type": "data"}
+		],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "30.5", "bit_rate": "45000000"}
+	}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
+
+	videoMeta, err := ValidateVideo(stagedVideo)
+	assert.NoError(t, err)
+	assert.Equal(t, db.VideoMedia{
+		Container:   "mp4",
+		VideoCodec:  "hevc",
+		Width:       3840,
+		Height:      2160,
+		FrameRate:   29.97,
+		BitRate:     45000000,
+		PixelFormat: "yuv420p10le",
+		Rotation:    90,
+		AudioCodec:  "aac",
+		SampleRate:  48000,
+		Channels:    2,
+		StreamCount: 3,
+	}, videoMeta.Media)
+}
+
+func TestProbeVideo_RotateTagAndNoAudio(t *testing.T) {
+	fakeCommands(t, func(string, []string) string {
+		return `{"streams":[{"codec_type":"video","codec_name":"h264","width":1280,"height":720,"avg_frame_rate":"0/0","r_frame_rate":"25/1","pix_fmt":"yuv420p","tags":{"rotate":"270"}}],"format":{"format_name":"matroska,webm","duration":"12.0"}}`
+	})
+	stagedVideo := stageTestVideo(t, testVideoData)
+
+	videoMeta, err := ProbeVideo(stagedVideo.Path)
+	assert.NoError(t, err)
+	assert.Equal(t, 12.0, videoMeta.FileDuration)
+	media := videoMeta.Media
+	assert.Equal(t, 25.0, media.FrameRate)
+	assert.Equal(t, 270, media.Rotation)
+	assert.Equal(t, "", media.AudioCodec)
+	assert.Equal(t, 0, media.Channels)
+	assert.Equal(t, int64(0), media.BitRate)
+}
+
+func TestParseFrameRate(t *testing.T) {
+	assert.Equal(t, 29.97, parseFrameRate("30000/1001"))
+	assert.Equal(t, 60.0, parseFrameRate("60/1"))
+	assert.Equal(t, 24.0, parseFrameRate("24"))
+	assert.Equal(t, 0.0, parseFrameRate("0/0"))
+	assert.Equal(t, 0.0, parseFrameRate(""))
 }
 
 // Trim Video
@@ -105,7 +231,7 @@ func TestTrimVideo_Success(t *testing.T) {
 		return exec.Command("cmd", "/C", "echo")
 	}
 
-	err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, 20)
+	err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, TrimOptions{Mode: TRIM_MODE_FAST})
 	assert.NoError(t, err)
 
 	execCommand = exec.Command
@@ -118,19 +244,62 @@ func TestTrimVideo_Failure(t *testing.T) {
 		return cmd
 	}
 
-	err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, 20)
+	err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, TrimOptions{Mode: TRIM_MODE_FAST})
 	assert.Error(t, err)
 
 	execCommand = exec.Command
 }
 
+func TestTrimVideo_Accurate(t *testing.T) {
+	runs := fakeCommands(t, func(string, []string) string {
+		return ""
+	})
+
+	err := TrimVideo("input.mkv", "output.mp4", 10.5, 30.5, TrimOptions{Mode: TRIM_MODE_ACCURATE})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"-ss", "10.500", "-i", "input.mkv", "-t", "20.000",
+		"-c:v", "libx264", "-crf", "18", "-preset", "medium", "-c:a", "aac", "-movflags", "+faststart",
+		"output.mp4",
+	}, (*runs)[0])
+}
+
+func TestTrimVideo_AccurateCodecAndCRF(t *testing.T) {
+	runs := fakeCommands(t, func(string, []string) string {
+		return ""
+	})
+
+	crf := 28
+	err := TrimVideo("input.mkv", "output.mp4", 10.5, 30.5, TrimOptions{Mode: TRIM_MODE_ACCURATE, VideoCodec: "hevc", CRF: &crf})
+	assert.NoError(t, err)
+	assert.Contains(t, strings.Join((*runs)[0], " "), "-c:v libx265 -crf 28 -preset medium -c:a aac")
+}
+
+func TestTrimVideo_InvalidEncoding(t *testing.T) {
+	crf, badCRF := 28, 52
+	for _, options := range []TrimOptions{
+		{Mode: TRIM_MODE_ACCURATE, VideoCodec: "vp9"},
+		{Mode: TRIM_MODE_ACCURATE, CRF: &badCRF},
+		{Mode: TRIM_MODE_FAST, CRF: &crf},
+		{Mode: TRIM_MODE_SMART, VideoCodec: "h264"},
+	} {
+		err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, options)
+		assert.Error(t, err, options)
+	}
+}
+
+func TestTrimVideo_UnknownMode(t *testing.T) {
+	err := TrimVideo("input.mp4", "output.mp4", 10.5, 30.5, TrimOptions{Mode: "smooth"})
+	assert.EqualError(t, err, `unknown trim mode "smooth"`)
+}
+
 // Merge video
 func TestMergeVideos_Success(t *testing.T) {
 	execCommand = func(command string, args ...string) *exec.Cmd {
 		return exec.Command("cmd", "/C", "echo")
 	}
 
-	err := MergeVideos([]string{"input1.mp4", "input2.mp4"}, "output.mp4")
+	err := MergeVideos([]string{"input1.mp4", "input2.mp4"}, "output.mp4", MergeTarget{})
 	assert.NoError(t, err)
 
 	execCommand = exec.Command
@@ -143,7 +312,7 @@ func TestMergeVideos_Failure(t *testing.T) {
 		return cmd
 	}
 
-	err := MergeVideos([]string{"input1.mp4", "input2.mp4"}, "output.mp4")
+	err := MergeVideos([]string{"input1.mp4", "input2.mp4"}, "output.mp4", MergeTarget{})
 	assert.Error(t, err)
 
 	execCommand = exec.Command
@@ -1,9 +1,20 @@
 package utils
 
-import "os"
+import (
+	"io"
+	"os"
+)
+
+type File interface {
+	io.ReadSeekCloser
+	io.ReaderAt
+	Stat() (os.FileInfo, error)
+}
 
 type FileSystem interface {
 	Stat(name string) (os.FileInfo, error)
+	Open(name string) (File, error)
+	Remove(name string) error
 }
 
 type OSFileSystem struct{}
@@ -11,3 +22,20 @@ type OSFileSystem struct{}
 func (OSFileSystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
+
+func (OSFileSystem) Open(name string) (File, error) {
+	return os.Open(name)
+}
+
+func (OSFileSystem) Remove(name string) error {
+	return os.Remove(name)
+}
+
+// RemoveIfExists removes the named file and treats an already missing file as success.
+func RemoveIfExists(fs FileSystem, name string) error {
+	if err := fs.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
@@ -1,23 +1,43 @@
 package mocks
 
 import (
+	"bytes"
 	"os"
 	"time"
 
+	"github.com/3ssalunke/videoverse/utils"
 	"github.com/stretchr/testify/mock"
 )
 
 type MockFileInfo struct {
-	FileName string
-	FileSize int64
+	FileName    string
+	FileSize    int64
+	FileModTime time.Time
 }
 
-func (m MockFileInfo) Name() string       { return m.FileName }
-func (m MockFileInfo) Size() int64        { return m.FileSize }
-func (m MockFileInfo) Mode() os.FileMode  { return 0644 }
-func (m MockFileInfo) ModTime() time.Time { return time.Now() }
-func (m MockFileInfo) IsDir() bool        { return false }
-func (m MockFileInfo) Sys() any           { return nil }
+func (m MockFileInfo) Name() string      { return m.FileName }
+func (m MockFileInfo) Size() int64       { return m.FileSize }
+func (m MockFileInfo) Mode() os.FileMode { return 0644 }
+func (m MockFileInfo) ModTime() time.Time {
+	if m.FileModTime.IsZero() {
+		return time.Now()
+	}
+	return m.FileModTime
+}
+func (m MockFileInfo) IsDir() bool { return false }
+func (m MockFileInfo) Sys() any    { return nil }
+
+type MockFile struct {
+	*bytes.Reader
+	Info MockFileInfo
+}
+
+func NewMockFile(data []byte) *MockFile {
+	return &MockFile{bytes.NewReader(data), MockFileInfo{FileSize: int64(len(data)), FileModTime: time.Unix(0, 0)}}
+}
+
+func (m *MockFile) Stat() (os.FileInfo, error) { return m.Info, nil }
+func (m *MockFile) Close() error               { return nil }
 
 type MockFileSystem struct {
 	mock.Mock
@@ -31,3 +51,17 @@ func (m *MockFileSystem) Stat(name string) (os.FileInfo, error) {
 
 	return nil, args.Error(1)
 }
+
+func (m *MockFileSystem) Open(name string) (utils.File, error) {
+	args := m.Called(name)
+	if args.Get(0) != nil {
+		return args.Get(0).(utils.File), args.Error(1)
+	}
+
+	return nil, args.Error(1)
+}
+
+func (m *MockFileSystem) Remove(name string) error {
+	args := m.Called(name)
+	return args.Error(0)
+}
@@ -1,11 +1,80 @@
 package utils
 
+import "time"
+
 type VideoTrimRequest struct {
 	VideoID string  `json:"video_id"`
 	StartTS float64 `json:"start_ts"`
 	EndTS   float64 `json:"end_ts"`
+	Mode    string  `json:"mode"`
+	Codec   string  `json:"codec"`
+	CRF     *int    `json:"crf"`
 }
 
 type VideosMergeRequest struct {
-	VideoIDs []string `json:"video_ids"`
+	VideoIDs []string          `json:"video_ids"`
+	Target   *VideoMergeTarget `json:"target"`
+}
+
+type VideoMergeTarget struct {
+	Width      int     `json:"width"`
+	Height     int     `json:"height"`
+	FrameRate  float64 `json:"frame_rate"`
+	SampleRate int     `json:"sample_rate"`
+	Channels   int     `json:"channels"`
+}
+
+type SharedLinkCreateRequest struct {
+	TTLSeconds int64      `json:"ttl_seconds"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+	Password   string     `json:"password"`
+	MaxViews   *int       `json:"max_views"`
+}
+
+type SharedLinkUpdateRequest struct {
+	TTLSeconds int64      `json:"ttl_seconds"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+}
+
+type SignedURLCreateRequest struct {
+	TTLSeconds int64      `json:"ttl_seconds"`
+	ExpiryDate *time.Time `json:"expiry_date"`
+	RangeStart *int64     `json:"range_start"`
+	RangeEnd   *int64     `json:"range_end"`
+}
+
+type VideoListRequest struct {
+	MinDuration            *float64   `form:"min_duration"`
+	MaxDuration            *float64   `form:"max_duration"`
+	MinSize                *int64     `form:"min_size"`
+	MaxSize                *int64     `form:"max_size"`
+	CreatedAfter           *time.Time `form:"created_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	CreatedBefore          *time.Time `form:"created_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	OriginalFilenamePrefix string     `form:"original_filename_prefix"`
+	TagsAny                []string   `form:"tags_any"`
+	TagsAll                []string   `form:"tags_all"`
+	Sort                   string     `form:"sort"`
+	Order                  string     `form:"order"`
+	Limit                  int        `form:"limit"`
+	Cursor                 string     `form:"cursor"`
+}
+
+type VideoSearchRequest struct {
+	Query  string `form:"q" binding:"required"`
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+type VideoUpdateRequest struct {
+	Title       *string            `json:"title"`
+	Description *string            `json:"description"`
+	Metadata    map[string]*string `json:"metadata"`
+}
+
+type VideoTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type VideoImportRequest struct {
+	URL string `json:"url" binding:"required"`
 }
@@ -0,0 +1,70 @@
+package workers
+
+import (
+	"log"
+	"path/filepath"
+	"strings"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/services"
+)
+
+// RejectedVideoError is returned by IngestStagedVideo when the video itself failed
+// validation, as opposed to the server failing to store it.
+type RejectedVideoError struct {
+	Err error
+}
+
+func (e *RejectedVideoError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RejectedVideoError) Unwrap() error {
+	return e.Err
+}
+
+// IngestStagedVideo validates a staged video, moves it into the store and creates its
+// video row. Uploads and imports both end here. When a video with the same content
+// already exists it is returned instead, with duplicate set, and the staged file is
+// left for the caller to discard.
+func IngestStagedVideo(videoRepo repository.VideoRepository, staged *services.StagedVideo) (video *db.Video, duplicate bool, err error) {
+	if staged.SHA256 != "" {
+		existing, err := videoRepo.GetVideoBySHA256(staged.SHA256)
+		if err != nil {
+			return nil, false, err
+		}
+		if existing != nil {
+			log.Printf("[worker] %s has the same content as video %s, skipping", staged.Filename, existing.ID)
+			return existing, true, nil
+		}
+	}
+
+	videoMeta, err := services.ValidateVideo(staged)
+	if err != nil {
+		return nil, false, &RejectedVideoError{err}
+	}
+
+	uploadedFile, err := services.UploadVideo(staged)
+	if err != nil {
+		return nil, false, err
+	}
+
+	video = &db.Video{
+		Name:             uploadedFile.Filename,
+		OriginalFilename: staged.Filename,
+		Path:             uploadedFile.FilePath,
+		Duration:         videoMeta.FileDuration,
+		Size:             videoMeta.FileSize,
+		Title:            strings.TrimSuffix(staged.Filename, filepath.Ext(staged.Filename)),
+		Metadata:         db.Metadata{},
+		SHA256:           staged.SHA256,
+		Media:            videoMeta.Media,
+	}
+
+	if err := videoRepo.CreateVideo(video); err != nil {
+		return nil, false, err
+	}
+
+	return video, false, nil
+}
@@ -0,0 +1,106 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/3ssalunke/videoverse/repository"
+)
+
+const (
+	SHARED_LINK_REAPER_INTERVAL  = time.Hour
+	SHARED_LINK_RETENTION_PERIOD = 7 * 24 * time.Hour
+	SHARED_LINK_REAPER_BATCH     = 500
+)
+
+// SHARED_LINK_REAPER_INTERVAL_ENV and SHARED_LINK_RETENTION_PERIOD_ENV override the
+// defaults above with a duration such as "30m" or "72h".
+const (
+	SHARED_LINK_REAPER_INTERVAL_ENV  = "SHARED_LINK_REAPER_INTERVAL"
+	SHARED_LINK_RETENTION_PERIOD_ENV = "SHARED_LINK_RETENTION_PERIOD"
+)
+
+// SharedLinkReaper periodically deletes shared links that expired longer than
+// the retention period ago.
+type SharedLinkReaper struct {
+	sharedLinkRepo repository.SharedLinkRepository
+	interval       time.Duration
+	retention      time.Duration
+	batchSize      int
+}
+
+func NewSharedLinkReaper(sharedLinkRepo repository.SharedLinkRepository, interval, retention time.Duration, batchSize int) *SharedLinkReaper {
+	return &SharedLinkReaper{sharedLinkRepo, interval, retention, batchSize}
+}
+
+// LoadSharedLinkReaperSettings reads the reaper interval and retention period from
+// the environment, falling back to the defaults for any that are unset.
+func LoadSharedLinkReaperSettings() (interval, retention time.Duration, err error) {
+	interval, err = durationFromEnv(SHARED_LINK_REAPER_INTERVAL_ENV, SHARED_LINK_REAPER_INTERVAL)
+	if err != nil {
+		return 0, 0, err
+	}
+	retention, err = durationFromEnv(SHARED_LINK_RETENTION_PERIOD_ENV, SHARED_LINK_RETENTION_PERIOD)
+	if err != nil {
+		return 0, 0, err
+	}
+	return interval, retention, nil
+}
+
+func durationFromEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("malformed %s %q: %w", name, value, err)
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got %s", name, value)
+	}
+	return duration, nil
+}
+
+// Start runs the reaper in the background until ctx is cancelled.
+func (r *SharedLinkReaper) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+
+		for {
+			r.Reap()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Reap deletes expired shared links in batches and returns the number of rows removed.
+func (r *SharedLinkReaper) Reap() int64 {
+	cutoff := time.Now().Add(-r.retention)
+
+	var total int64
+	for {
+		deleted, err := r.sharedLinkRepo.DeleteExpiredSharedLinks(cutoff, r.batchSize)
+		if err != nil {
+			log.Printf("[worker] failed to reap expired shared links: %s", err.Error())
+			break
+		}
+		total += deleted
+		if deleted < int64(r.batchSize) {
+			break
+		}
+	}
+
+	log.Printf("[worker] reaped %d shared links expired before %s", total, cutoff.Format(time.RFC3339))
+	return total
+}
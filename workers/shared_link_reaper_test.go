@@ -0,0 +1,65 @@
+package workers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReap_DeletesInBatches(t *testing.T) {
+	mockSharedLinkRepo := new(repoMock.MockSharedLinkRepositoryImpl)
+	mockSharedLinkRepo.On("DeleteExpiredSharedLinks", mock.AnythingOfType("time.Time"), 2).Return(int64(2), nil).Twice()
+	mockSharedLinkRepo.On("DeleteExpiredSharedLinks", mock.AnythingOfType("time.Time"), 2).Return(int64(1), nil).Once()
+
+	reaper := NewSharedLinkReaper(mockSharedLinkRepo, time.Hour, 24*time.Hour, 2)
+
+	assert.Equal(t, int64(5), reaper.Reap())
+	mockSharedLinkRepo.AssertExpectations(t)
+}
+
+func TestReap_UsesRetentionCutoff(t *testing.T) {
+	mockSharedLinkRepo := new(repoMock.MockSharedLinkRepositoryImpl)
+	mockSharedLinkRepo.On("DeleteExpiredSharedLinks", mock.MatchedBy(func(before time.Time) bool {
+		return before.Before(time.Now().Add(-23*time.Hour)) && before.After(time.Now().Add(-25*time.Hour))
+	}), 10).Return(int64(0), nil)
+
+	reaper := NewSharedLinkReaper(mockSharedLinkRepo, time.Hour, 24*time.Hour, 10)
+
+	assert.Equal(t, int64(0), reaper.Reap())
+	mockSharedLinkRepo.AssertExpectations(t)
+}
+
+func TestReap_StopsOnError(t *testing.T) {
+	mockSharedLinkRepo := new(repoMock.MockSharedLinkRepositoryImpl)
+	mockSharedLinkRepo.On("DeleteExpiredSharedLinks", mock.AnythingOfType("time.Time"), 2).Return(int64(2), nil).Once()
+	mockSharedLinkRepo.On("DeleteExpiredSharedLinks", mock.AnythingOfType("time.Time"), 2).Return(int64(0), errors.New("database error")).Once()
+
+	reaper := NewSharedLinkReaper(mockSharedLinkRepo, time.Hour, 24*time.Hour, 2)
+
+	assert.Equal(t, int64(2), reaper.Reap())
+	mockSharedLinkRepo.AssertExpectations(t)
+}
+
+func TestLoadSharedLinkReaperSettings(t *testing.T) {
+	interval, retention, err := LoadSharedLinkReaperSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, SHARED_LINK_REAPER_INTERVAL, interval)
+	assert.Equal(t, SHARED_LINK_RETENTION_PERIOD, retention)
+
+	t.Setenv(SHARED_LINK_REAPER_INTERVAL_ENV, "15m")
+	t.Setenv(SHARED_LINK_RETENTION_PERIOD_ENV, "72h")
+	interval, retention, err = LoadSharedLinkReaperSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, 15*time.Minute, interval)
+	assert.Equal(t, 72*time.Hour, retention)
+
+	for _, value := range []string{"hourly", "0s", "-1h"} {
+		t.Setenv(SHARED_LINK_REAPER_INTERVAL_ENV, value)
+		_, _, err = LoadSharedLinkReaperSettings()
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,93 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/utils"
+)
+
+const (
+	TRASH_JANITOR_INTERVAL = 6 * time.Hour
+	TRASH_RETENTION_PERIOD = 30 * 24 * time.Hour
+	TRASH_JANITOR_BATCH    = 100
+)
+
+// TrashJanitor periodically purges videos that have been in the trash for longer
+// than the retention period, together with their files.
+type TrashJanitor struct {
+	videoRepo repository.VideoRepository
+	fs        utils.FileSystem
+	interval  time.Duration
+	retention time.Duration
+	batchSize int
+}
+
+func NewTrashJanitor(videoRepo repository.VideoRepository, fs utils.FileSystem, interval, retention time.Duration, batchSize int) *TrashJanitor {
+	return &TrashJanitor{videoRepo, fs, interval, retention, batchSize}
+}
+
+// Start runs the janitor in the background until ctx is cancelled.
+func (j *TrashJanitor) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			j.Purge()
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Purge permanently deletes videos trashed before the retention cutoff and returns
+// how many were removed.
+func (j *TrashJanitor) Purge() int {
+	cutoff := time.Now().Add(-j.retention)
+
+	purged, err := PurgeTrashedVideos(j.videoRepo, j.fs, cutoff, j.batchSize)
+	if err != nil {
+		log.Printf("[worker] failed to purge trashed videos: %s", err.Error())
+	}
+
+	log.Printf("[worker] purged %d videos trashed before %s", purged, cutoff.Format(time.RFC3339))
+	return purged
+}
+
+// PurgeTrashedVideos permanently deletes, batch by batch, every video trashed before
+// the given time along with its file. It stops at the first video that can not be
+// deleted so a persistent failure does not loop forever.
+func PurgeTrashedVideos(videoRepo repository.VideoRepository, fs utils.FileSystem, deletedBefore time.Time, batchSize int) (int, error) {
+	purged := 0
+	for {
+		videos, err := videoRepo.GetTrashedVideos(deletedBefore, batchSize)
+		if err != nil {
+			return purged, err
+		}
+
+		for _, video := range videos {
+			if err := purgeVideo(videoRepo, fs, video); err != nil {
+				return purged, err
+			}
+			purged++
+		}
+
+		if len(videos) < batchSize {
+			return purged, nil
+		}
+	}
+}
+
+func purgeVideo(videoRepo repository.VideoRepository, fs utils.FileSystem, video db.Video) error {
+	return videoRepo.DeleteVideo(&video, func() error {
+		return utils.RemoveIfExists(fs, video.Path)
+	})
+}
@@ -0,0 +1,50 @@
+package workers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/3ssalunke/videoverse/db"
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	fsMock "github.com/3ssalunke/videoverse/utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurge_RemovesTrashedVideos(t *testing.T) {
+	mockRepo := new(repoMock.MockVideoRepositoryImpl)
+	mockFS := new(fsMock.MockFileSystem)
+	mockRepo.On("GetTrashedVideos", mock.AnythingOfType("time.Time"), 2).Return([]db.Video{
+		{ID: "video-id-1", Path: "videos/test-1.mp4"},
+		{ID: "video-id-2", Path: "videos/test-2.mp4"},
+	}, nil).Once()
+	mockRepo.On("GetTrashedVideos", mock.AnythingOfType("time.Time"), 2).Return([]db.Video{
+		{ID: "video-id-3", Path: "videos/test-3.mp4"},
+	}, nil).Once()
+	mockRepo.On("DeleteVideo", mock.Anything).Return(nil)
+	mockFS.On("Remove", mock.AnythingOfType("string")).Return(nil)
+
+	janitor := NewTrashJanitor(mockRepo, mockFS, time.Hour, 24*time.Hour, 2)
+
+	assert.Equal(t, 3, janitor.Purge())
+	mockRepo.AssertExpectations(t)
+	mockFS.AssertNumberOfCalls(t, "Remove", 3)
+}
+
+func TestPurge_StopsOnDeleteFailure(t *testing.T) {
+	mockRepo := new(repoMock.MockVideoRepositoryImpl)
+	mockFS := new(fsMock.MockFileSystem)
+	mockRepo.On("GetTrashedVideos", mock.AnythingOfType("time.Time"), 2).Return([]db.Video{
+		{ID: "video-id-1", Path: "videos/test-1.mp4"},
+		{ID: "video-id-2", Path: "videos/test-2.mp4"},
+	}, nil)
+	mockRepo.On("DeleteVideo", mock.Anything).Return(nil).Once()
+	mockRepo.On("DeleteVideo", mock.Anything).Return(errors.New("error deleting video")).Once()
+	mockFS.On("Remove", mock.AnythingOfType("string")).Return(nil)
+
+	janitor := NewTrashJanitor(mockRepo, mockFS, time.Hour, 24*time.Hour, 2)
+
+	assert.Equal(t, 1, janitor.Purge())
+	mockRepo.AssertExpectations(t)
+}
@@ -0,0 +1,111 @@
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/3ssalunke/videoverse/db"
+	"github.com/3ssalunke/videoverse/repository"
+	"github.com/3ssalunke/videoverse/services"
+)
+
+const (
+	VIDEO_IMPORTER_CONCURRENCY = 2
+	VIDEO_IMPORTER_QUEUE_SIZE  = 100
+)
+
+// ImportQueue accepts import jobs to run in the background.
+type ImportQueue interface {
+	// Enqueue reports false when the queue is full.
+	Enqueue(job db.ImportJob) bool
+}
+
+// VideoImporter downloads queued import jobs with a fixed number of goroutines and
+// records the outcome on each job.
+type VideoImporter struct {
+	importJobRepo repository.ImportJobRepository
+	videoRepo     repository.VideoRepository
+	concurrency   int
+	jobs          chan db.ImportJob
+}
+
+func NewVideoImporter(importJobRepo repository.ImportJobRepository, videoRepo repository.VideoRepository, concurrency, queueSize int) *VideoImporter {
+	return &VideoImporter{importJobRepo, videoRepo, concurrency, make(chan db.ImportJob, queueSize)}
+}
+
+// Start requeues the jobs left unfinished by a previous run and processes the queue
+// in the background until ctx is cancelled.
+func (i *VideoImporter) Start(ctx context.Context) {
+	for n := 0; n < i.concurrency; n++ {
+		go func() {
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-i.jobs:
+					i.Import(ctx, job)
+				}
+			}
+		}()
+	}
+
+	jobs, err := i.importJobRepo.GetUnfinishedImportJobs()
+	if err != nil {
+		log.Printf("[worker] failed to requeue unfinished imports: %s", err.Error())
+		return
+	}
+	for _, job := range jobs {
+		if !i.Enqueue(job) {
+			i.fail(&job, "import queue is full")
+		}
+	}
+}
+
+func (i *VideoImporter) Enqueue(job db.ImportJob) bool {
+	select {
+	case i.jobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// Import downloads the job's url and ingests it like an upload.
+func (i *VideoImporter) Import(ctx context.Context, job db.ImportJob) {
+	job.Status = db.IMPORT_RUNNING
+	if err := i.importJobRepo.UpdateImportJob(&job); err != nil {
+		log.Printf("[worker] failed to mark import %s as running: %s", job.ID, err.Error())
+	}
+
+	staged, err := services.DownloadVideo(ctx, job.URL)
+	if err != nil {
+		i.fail(&job, err.Error())
+		return
+	}
+	defer services.DiscardStagedVideo(staged)
+
+	video, _, err := IngestStagedVideo(i.videoRepo, staged)
+	if err != nil {
+		i.fail(&job, err.Error())
+		return
+	}
+
+	job.Status = db.IMPORT_SUCCEEDED
+	job.VideoID = &video.ID
+	if err := i.importJobRepo.UpdateImportJob(&job); err != nil {
+		log.Printf("[worker] failed to mark import %s as succeeded: %s", job.ID, err.Error())
+		return
+	}
+
+	log.Printf("[worker] imported %s as video %s", job.URL, video.ID)
+}
+
+func (i *VideoImporter) fail(job *db.ImportJob, reason string) {
+	log.Printf("[worker] import %s failed: %s", job.ID, reason)
+
+	job.Status = db.IMPORT_FAILED
+	job.Error = reason
+	if err := i.importJobRepo.UpdateImportJob(job); err != nil {
+		log.Printf("[worker] failed to mark import %s as failed: %s", job.ID, err.Error())
+	}
+}
@@ -0,0 +1,119 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/3ssalunke/videoverse/db"
+	repoMock "github.com/3ssalunke/videoverse/repository/mocks"
+	"github.com/3ssalunke/videoverse/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func mockImportPipeline(t *testing.T, downloadErr, validateErr error) {
+	download, validate, upload := services.DownloadVideo, services.ValidateVideo, services.UploadVideo
+	t.Cleanup(func() {
+		services.DownloadVideo, services.ValidateVideo, services.UploadVideo = download, validate, upload
+	})
+
+	services.DownloadVideo = func(ctx context.Context, rawURL string) (*services.StagedVideo, error) {
+		if downloadErr != nil {
+			return nil, downloadErr
+		}
+		return &services.StagedVideo{Path: "/mock/path/staged", Filename: "clip.mp4", Size: 1024}, nil
+	}
+	services.ValidateVideo = func(video *services.StagedVideo) (*services.VideoMeta, error) {
+		if validateErr != nil {
+			return nil, validateErr
+		}
+		return &services.VideoMeta{FileSize: video.Size, FileDuration: 30}, nil
+	}
+	services.UploadVideo = func(video *services.StagedVideo) (*services.UploadedVideo, error) {
+		return &services.UploadedVideo{Filename: "1-clip.mp4", FilePath: "video_store/1-clip.mp4"}, nil
+	}
+}
+
+func TestImport_Success(t *testing.T) {
+	mockImportPipeline(t, nil, nil)
+
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	mockVideoRepo := new(repoMock.MockVideoRepositoryImpl)
+	mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		return job.Status == db.IMPORT_RUNNING
+	})).Return(nil).Once()
+	mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		return job.Status == db.IMPORT_SUCCEEDED && job.VideoID != nil && *job.VideoID == "video-id"
+	})).Return(nil).Once()
+	mockVideoRepo.On("CreateVideo", mock.MatchedBy(func(video *db.Video) bool {
+		video.ID = "video-id"
+		return video.Title == "clip" && video.Duration == 30
+	})).Return(nil)
+
+	importer := NewVideoImporter(mockImportRepo, mockVideoRepo, 1, 1)
+	importer.Import(context.Background(), db.ImportJob{ID: "job-id", URL: "https://example.com/clip.mp4"})
+
+	mockImportRepo.AssertExpectations(t)
+	mockVideoRepo.AssertExpectations(t)
+}
+
+func TestImport_RecordsFailure(t *testing.T) {
+	for _, tc := range []struct {
+		name                     string
+		downloadErr, validateErr error
+		reason                   string
+	}{
+		{"download", errors.New("remote server responded with 404 Not Found"), nil, "remote server responded with 404 Not Found"},
+		{"validation", nil, errors.New("video duration must be between 5 and 50 seconds"), "video duration must be between 5 and 50 seconds"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			mockImportPipeline(t, tc.downloadErr, tc.validateErr)
+
+			mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+			mockVideoRepo := new(repoMock.MockVideoRepositoryImpl)
+			mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+				return job.Status == db.IMPORT_RUNNING
+			})).Return(nil).Once()
+			mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+				return job.Status == db.IMPORT_FAILED && job.Error == tc.reason
+			})).Return(nil).Once()
+
+			importer := NewVideoImporter(mockImportRepo, mockVideoRepo, 1, 1)
+			importer.Import(context.Background(), db.ImportJob{ID: "job-id", URL: "https://example.com/clip.mp4"})
+
+			mockImportRepo.AssertExpectations(t)
+			mockVideoRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+		})
+	}
+}
+
+func TestEnqueue_ReportsFullQueue(t *testing.T) {
+	importer := NewVideoImporter(new(repoMock.MockImportJobRepositoryImpl), new(repoMock.MockVideoRepositoryImpl), 1, 1)
+
+	assert.True(t, importer.Enqueue(db.ImportJob{ID: "job-1"}))
+	assert.False(t, importer.Enqueue(db.ImportJob{ID: "job-2"}))
+}
+
+func TestImport_DuplicateContentReusesVideo(t *testing.T) {
+	mockImportPipeline(t, nil, nil)
+	services.DownloadVideo = func(ctx context.Context, rawURL string) (*services.StagedVideo, error) {
+		return &services.StagedVideo{Path: "/mock/path/staged", Filename: "clip.mp4", Size: 1024, SHA256: "abc123"}, nil
+	}
+
+	mockImportRepo := new(repoMock.MockImportJobRepositoryImpl)
+	mockVideoRepo := new(repoMock.MockVideoRepositoryImpl)
+	mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		return job.Status == db.IMPORT_RUNNING
+	})).Return(nil).Once()
+	mockImportRepo.On("UpdateImportJob", mock.MatchedBy(func(job *db.ImportJob) bool {
+		return job.Status == db.IMPORT_SUCCEEDED && *job.VideoID == "existing-id"
+	})).Return(nil).Once()
+	mockVideoRepo.On("GetVideoBySHA256", "abc123").Return(&db.Video{ID: "existing-id"}, nil)
+
+	importer := NewVideoImporter(mockImportRepo, mockVideoRepo, 1, 1)
+	importer.Import(context.Background(), db.ImportJob{ID: "job-id", URL: "https://example.com/clip.mp4"})
+
+	mockImportRepo.AssertExpectations(t)
+	mockVideoRepo.AssertNotCalled(t, "CreateVideo", mock.Anything)
+}